@@ -14,8 +14,24 @@ func main() {
 
 	// Drop existing tables
 	dbConn.Migrator().DropTable(
+		&models.ChatPost{},
+		&models.ChatIntegration{},
+		&models.SubscriberNotification{},
+		&models.Subscriber{},
+		&models.WebhookDelivery{},
+		&models.WebhookEndpoint{},
+		&models.WebhookEvent{},
+		&models.APIKey{},
+		&models.SLOAlert{},
+		&models.SLO{},
+		&models.ServiceUptimeDaily{},
+		&models.ServiceStatusChange{},
+		&models.MonitorCheckResult{},
+		&models.Monitor{},
+		&models.OrganizationInvitation{},
 		&models.OrganizationMember{},
 		&models.IncidentUpdate{},
+		&models.IncidentService{},
 		&models.Incident{},
 		&models.Maintenance{},
 		&models.Service{},
@@ -27,9 +43,25 @@ func main() {
 		&models.Organization{},
 		&models.Service{},
 		&models.Incident{},
+		&models.IncidentService{},
 		&models.IncidentUpdate{},
 		&models.Maintenance{},
 		&models.OrganizationMember{},
+		&models.OrganizationInvitation{},
+		&models.Monitor{},
+		&models.MonitorCheckResult{},
+		&models.ServiceStatusChange{},
+		&models.ServiceUptimeDaily{},
+		&models.SLO{},
+		&models.SLOAlert{},
+		&models.APIKey{},
+		&models.WebhookEvent{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.Subscriber{},
+		&models.SubscriberNotification{},
+		&models.ChatIntegration{},
+		&models.ChatPost{},
 	)
 
 	// Create demo organization
@@ -45,12 +77,12 @@ func main() {
 		{
 			ClerkUserID:    "user_2fDz8sLk9PZJmRnQ4tGbWALeExi",
 			OrganizationID: org.ID,
-			Role:           "admin",
+			Role:           "owner",
 		},
 		{
 			ClerkUserID:    "user_5tHj4kLm7PdRnQ9WzVbCXeExiAl",
 			OrganizationID: org.ID,
-			Role:           "member",
+			Role:           "responder",
 		},
 	}
 	dbConn.Create(&members)
@@ -75,17 +107,22 @@ func main() {
 	dbConn.Create(&services)
 
 	// Create incidents with nested updates
+	identifiedAt := time.Now().Add(-time.Hour)
+	resolvedAt := time.Now().Add(-72 * time.Hour)
 	incidents := []models.Incident{
 		{
 			Title:          "API Latency Spike",
 			Description:    "Increased response times across endpoints",
 			Status:         "identified",
 			Severity:       "high",
-			ServiceID:      fmt.Sprint(services[0].ID),
+			IdentifiedAt:   &identifiedAt,
 			OrganizationID: org.ID,
+			Services: []models.IncidentService{
+				{ServiceID: fmt.Sprint(services[0].ID), Impact: "partial_outage"},
+			},
 			Updates: []models.IncidentUpdate{
-				{Message: "Initial investigation started"},
-				{Message: "Identified overloaded caching layer"},
+				{Message: "Initial investigation started", Status: "investigating", Timestamp: identifiedAt.Add(-30 * time.Minute)},
+				{Message: "Identified overloaded caching layer", Status: "identified", Timestamp: identifiedAt},
 			},
 		},
 		{
@@ -93,10 +130,12 @@ func main() {
 			Description:    "Primary-replica synchronization delay",
 			Status:         "investigating",
 			Severity:       "medium",
-			ServiceID:      fmt.Sprint(services[1].ID),
 			OrganizationID: org.ID,
+			Services: []models.IncidentService{
+				{ServiceID: fmt.Sprint(services[1].ID), Impact: "degraded"},
+			},
 			Updates: []models.IncidentUpdate{
-				{Message: "Monitoring alerts triggered"},
+				{Message: "Monitoring alerts triggered", Status: "investigating", Timestamp: time.Now().Add(-15 * time.Minute)},
 			},
 		},
 		{
@@ -104,10 +143,14 @@ func main() {
 			Description:    "Increased response times across endpoints",
 			Status:         "resolved",
 			Severity:       "medium",
-			ServiceID:      fmt.Sprint(services[0].ID),
+			ResolvedAt:     &resolvedAt,
 			OrganizationID: org.ID,
+			Services: []models.IncidentService{
+				{ServiceID: fmt.Sprint(services[0].ID), Impact: "degraded"},
+			},
 			Updates: []models.IncidentUpdate{
-				{Message: "Monitoring alerts triggered"},
+				{Message: "Monitoring alerts triggered", Status: "investigating", Timestamp: resolvedAt.Add(-2 * time.Hour)},
+				{Message: "This incident has been resolved.", Status: "resolved", Timestamp: resolvedAt},
 			},
 		},
 	}
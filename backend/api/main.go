@@ -1,17 +1,23 @@
 package main
 
 import (
-	// "github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"context"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/chat"
 	"github.com/apsinghdev/PopenStatus/api/pkg/db"
 	"github.com/apsinghdev/PopenStatus/api/pkg/handlers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/monitor"
 	// "github.com/apsinghdev/PopenStatus/api/pkg/models"
 	"github.com/apsinghdev/PopenStatus/api/pkg/routes"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/slo"
+	"github.com/apsinghdev/PopenStatus/api/pkg/subscribers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/uptime"
+	"github.com/apsinghdev/PopenStatus/api/pkg/webhooks"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
-// TODO: enable auth later
-
 func main() {
 	app := fiber.New()
 
@@ -27,6 +33,35 @@ func main() {
 	// Initialize database
 	db.Connect()
 
+	// Move incidents from a single service to the incident_services table
+	if err := db.MigrateIncidentServices(db.GetDB()); err != nil {
+		panic("failed to migrate incident services: " + err.Error())
+	}
+
+	// Run health checks in the background
+	monitor.NewScheduler(db.GetDB()).Start(context.Background())
+
+	// Roll up the daily uptime of every service
+	uptime.NewRoller(db.GetDB()).Start(context.Background())
+
+	// Alert on SLOs burning their error budget too fast
+	slo.NewEvaluator(db.GetDB()).Start(context.Background())
+
+	// Start and complete maintenances at their scheduled times
+	services.NewMaintenanceScheduler(db.GetDB()).Start(context.Background())
+
+	// Send services back to their computed status once manual overrides expire
+	services.NewOverrideExpirer(db.GetDB()).Start(context.Background())
+
+	// Deliver status, incident and maintenance events to organizations' webhook endpoints
+	webhooks.NewDispatcher(db.GetDB()).Start(context.Background())
+
+	// Email incidents and maintenances to the confirmed subscribers of each organization
+	subscribers.NewNotifier(db.GetDB(), subscribers.NewSenderFromEnv()).Start(context.Background())
+
+	// Post incidents and status changes to organizations' Slack, Discord and Teams channels
+	chat.NewDispatcher(db.GetDB()).Start(context.Background())
+
 	// // Drop existing tables
 	// database.Migrator().DropTable(
 	// 	&models.Organization{},
@@ -50,10 +85,9 @@ func main() {
 	// 	panic("failed to migrate schema: " + err.Error())
 	// }
 
-	// app.Use(auth.ClerkMiddleware())
-
 	// Register routes
 	routes.ServiceRoutes(app)
+	routes.RealtimeRoutes(app)
 
 	// Register webhook handler
 	app.Post("/webhooks/clerk", handlers.HandleClerkWebhook)
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key so that keys are told apart from Clerk
+// session tokens and are easy to spot in leaked secrets
+const APIKeyPrefix = "ps_"
+
+// apiKeyKey is the request local holding the API key of the caller
+const apiKeyKey = "apiKey"
+
+// Scopes lists the scopes an API key can be granted
+var Scopes = []string{
+	models.ScopeServicesWrite,
+	models.ScopeIncidentsWrite,
+	models.ScopeMaintenancesWrite,
+	models.ScopeMonitorsWrite,
+	models.ScopeSLOsWrite,
+}
+
+// ErrInvalidAPIKey is returned for API keys that are unknown, revoked or expired
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// GenerateAPIKey returns a new key of the form ps_<prefix>_<secret> along
+// with its prefix, which identifies the key, and the hash stored in its place
+func GenerateAPIKey() (key string, prefix string, hash string, err error) {
+	buf := make([]byte, 28)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	encoded := hex.EncodeToString(buf)
+
+	prefix = encoded[:8]
+	key = APIKeyPrefix + prefix + "_" + encoded[8:]
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash an API key is stored as. Keys are long random
+// secrets, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a Clerk session token
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// AuthenticateAPIKey looks up the active key matching token and records its use
+func AuthenticateAPIKey(database *gorm.DB, token string, now time.Time) (models.APIKey, error) {
+	parts := strings.SplitN(strings.TrimPrefix(token, APIKeyPrefix), "_", 2)
+	if len(parts) != 2 {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := database.Where("prefix = ?", parts[0]).First(&key).Error; err != nil {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(token)), []byte(key.KeyHash)) != 1 {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	if err := database.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+		return models.APIKey{}, err
+	}
+	key.LastUsedAt = &now
+
+	return key, nil
+}
+
+// HasScope reports whether the API key was granted the scope
+func HasScope(key models.APIKey, scope string) bool {
+	for _, granted := range strings.Split(key.Scopes, ",") {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey returns the API key the caller authenticated with, if any
+func APIKey(c *fiber.Ctx) (models.APIKey, bool) {
+	key, ok := c.Locals(apiKeyKey).(models.APIKey)
+	return key, ok
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Supported values of AUTH_PROVIDER
+const (
+	ProviderClerk  = "clerk"
+	ProviderOIDC   = "oidc"
+	ProviderStatic = "static"
+)
+
+// ErrInvalidToken is returned by authenticators for tokens they do not accept
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity is the caller a bearer token belongs to
+type Identity struct {
+	UserID         string // Matched against OrganizationMember.ClerkUserID
+	OrganizationID string // Active organization, matched against Organization.ClerkOrgID
+}
+
+// Authenticator verifies the bearer tokens of user sessions
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (Identity, error)
+}
+
+// NewAuthenticatorFromEnv returns the authenticator selected by AUTH_PROVIDER,
+// Clerk when it is not set
+func NewAuthenticatorFromEnv() (Authenticator, error) {
+	// Each case returns its own result so a failed constructor yields a nil
+	// interface rather than one holding a nil pointer
+	switch provider := os.Getenv("AUTH_PROVIDER"); provider {
+	case "", ProviderClerk:
+		authenticator, err := NewClerkAuthenticator(os.Getenv("CLERK_SECRET_KEY"))
+		if err != nil {
+			return nil, err
+		}
+		return authenticator, nil
+	case ProviderOIDC:
+		authenticator, err := NewOIDCAuthenticator(OIDCConfig{
+			JWKSURL:  os.Getenv("OIDC_JWKS_URL"),
+			Issuer:   os.Getenv("OIDC_ISSUER"),
+			Audience: os.Getenv("OIDC_AUDIENCE"),
+			OrgClaim: os.Getenv("OIDC_ORG_CLAIM"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return authenticator, nil
+	case ProviderStatic:
+		authenticator, err := NewStaticAuthenticator(os.Getenv("AUTH_STATIC_TOKENS"))
+		if err != nil {
+			return nil, err
+		}
+		return authenticator, nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q", provider)
+	}
+}
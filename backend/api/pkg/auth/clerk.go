@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/clerkinc/clerk-sdk-go/clerk"
+)
+
+// ClerkAuthenticator verifies Clerk session tokens
+type ClerkAuthenticator struct {
+	client clerk.Client
+}
+
+func NewClerkAuthenticator(secretKey string) (*ClerkAuthenticator, error) {
+	client, err := clerk.NewClient(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Clerk client: %w", err)
+	}
+	return &ClerkAuthenticator{client: client}, nil
+}
+
+// Authenticate verifies a Clerk session token
+func (a *ClerkAuthenticator) Authenticate(ctx context.Context, token string) (Identity, error) {
+	claims, err := a.client.VerifyToken(token)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return Identity{
+		UserID:         claims.Subject,
+		OrganizationID: claims.ActiveOrganizationID,
+	}, nil
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwksCacheDuration is how long fetched signing keys are reused
+	jwksCacheDuration = 10 * time.Minute
+	// jwksRefetchInterval is the least time between two fetches of the
+	// signing keys, whatever key IDs the tokens ask for
+	jwksRefetchInterval = 30 * time.Second
+	// defaultOrgClaim is the claim holding the active organization, as in Clerk tokens
+	defaultOrgClaim = "org_id"
+	// clockLeeway allows for clock skew between the issuer and the API
+	clockLeeway = time.Minute
+)
+
+// OIDCConfig configures the validation of JWTs from an OIDC provider
+type OIDCConfig struct {
+	// JWKSURL serves the provider's signing keys. A file:// URL reads them
+	// from disk, which lets the API run offline against a local key set.
+	JWKSURL  string
+	Issuer   string // Expected iss claim, not checked when empty
+	Audience string // Expected aud claim, required so tokens minted for other apps are refused
+	OrgClaim string // Claim holding the organization ID, dots reach into nested claims
+}
+
+// OIDCAuthenticator validates JWTs signed with the keys of a JWKS
+type OIDCAuthenticator struct {
+	config OIDCConfig
+	client *http.Client
+
+	fetches     singleflight.Group
+	mu          sync.Mutex
+	keys        jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time // Last fetch, successful or not
+}
+
+func NewOIDCAuthenticator(config OIDCConfig) (*OIDCAuthenticator, error) {
+	if config.JWKSURL == "" {
+		return nil, errors.New("OIDC_JWKS_URL is not set")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("OIDC_AUDIENCE is not set")
+	}
+	if config.OrgClaim == "" {
+		config.OrgClaim = defaultOrgClaim
+	}
+
+	return &OIDCAuthenticator{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Authenticate validates the signature and standard claims of a JWT and
+// reads the organization from the configured claim
+func (a *OIDCAuthenticator) Authenticate(ctx context.Context, token string) (Identity, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if len(parsed.Headers) == 0 {
+		return Identity{}, ErrInvalidToken
+	}
+
+	key, err := a.signingKey(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	var claims jwt.Claims
+	var custom map[string]interface{}
+	if err := parsed.Claims(key.Key, &claims, &custom); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// Validation skips claims that are absent, but a token must expire
+	if claims.Expiry == nil {
+		return Identity{}, fmt.Errorf("%w: missing expiry", ErrInvalidToken)
+	}
+	expected := jwt.Expected{
+		Issuer:   a.config.Issuer,
+		Audience: jwt.Audience{a.config.Audience},
+		Time:     time.Now(),
+	}
+	if err := claims.ValidateWithLeeway(expected, clockLeeway); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	orgID, _ := lookupClaim(custom, a.config.OrgClaim).(string)
+
+	return Identity{UserID: claims.Subject, OrganizationID: orgID}, nil
+}
+
+// signingKey returns the key with the given ID, fetching the key set again
+// when it is stale or does not know the key yet, e.g. after a key rotation.
+// Key IDs cost nothing to make up, so fetches happen at most once per
+// jwksRefetchInterval and concurrent requests wait for the same one.
+func (a *OIDCAuthenticator) signingKey(ctx context.Context, keyID string) (jose.JSONWebKey, error) {
+	a.mu.Lock()
+	keySet := a.keys
+	fresh := time.Since(a.fetchedAt) < jwksCacheDuration
+	recentlyFetched := time.Since(a.attemptedAt) < jwksRefetchInterval
+	a.mu.Unlock()
+
+	if fresh || recentlyFetched {
+		if keys := keySet.Key(keyID); len(keys) > 0 {
+			return keys[0], nil
+		}
+	}
+	if recentlyFetched {
+		return jose.JSONWebKey{}, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, keyID)
+	}
+
+	keySet, err := a.refreshKeys(ctx)
+	if err != nil {
+		return jose.JSONWebKey{}, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := keySet.Key(keyID)
+	if len(keys) == 0 {
+		return jose.JSONWebKey{}, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, keyID)
+	}
+	return keys[0], nil
+}
+
+// refreshKeys fetches the key set without holding the lock, sharing the
+// fetch between concurrent callers
+func (a *OIDCAuthenticator) refreshKeys(ctx context.Context) (jose.JSONWebKeySet, error) {
+	result, err, _ := a.fetches.Do("jwks", func() (interface{}, error) {
+		a.mu.Lock()
+		a.attemptedAt = time.Now()
+		a.mu.Unlock()
+
+		// The fetch is shared, so one caller going away must not cancel it
+		keySet, err := a.fetchKeys(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		a.mu.Lock()
+		a.keys = keySet
+		a.fetchedAt = time.Now()
+		a.mu.Unlock()
+
+		return keySet, nil
+	})
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	return result.(jose.JSONWebKeySet), nil
+}
+
+func (a *OIDCAuthenticator) fetchKeys(ctx context.Context) (jose.JSONWebKeySet, error) {
+	var keySet jose.JSONWebKeySet
+
+	var body []byte
+	if path, ok := strings.CutPrefix(a.config.JWKSURL, "file://"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return keySet, err
+		}
+		body = data
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.config.JWKSURL, nil)
+		if err != nil {
+			return keySet, err
+		}
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return keySet, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return keySet, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			return keySet, err
+		}
+	}
+
+	if err := json.Unmarshal(body, &keySet); err != nil {
+		return keySet, err
+	}
+	return keySet, nil
+}
+
+// lookupClaim returns the claim at a dotted path such as "o.id"
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// jwksServer serves a key set the test can rotate and counts its fetches
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    jose.JSONWebKeySet
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...*rsa.PrivateKey) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{}
+	s.setKeys(keys...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		json.NewEncoder(w).Encode(s.keys)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// setKeys publishes the public halves of the keys, named by keyID
+func (s *jwksServer) setKeys(keys ...*rsa.PrivateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = jose.JSONWebKeySet{}
+	for _, key := range keys {
+		s.keys.Keys = append(s.keys.Keys, jose.JSONWebKey{
+			Key:       &key.PublicKey,
+			KeyID:     keyID(key),
+			Algorithm: string(jose.RS256),
+			Use:       "sig",
+		})
+	}
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func keyID(key *rsa.PrivateKey) string {
+	return key.PublicKey.N.Text(36)[:16]
+}
+
+// signToken returns a JWT signed with the key, naming it with kid
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.Claims, custom map[string]interface{}) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: kid}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Claims(custom).CompactSerialize()
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func validClaims() jwt.Claims {
+	now := time.Now()
+	return jwt.Claims{
+		Subject:  "user_1",
+		Issuer:   "https://issuer.test",
+		Audience: jwt.Audience{"popenstatus"},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func newTestAuthenticator(t *testing.T, server *jwksServer) *OIDCAuthenticator {
+	t.Helper()
+
+	authenticator, err := NewOIDCAuthenticator(OIDCConfig{
+		JWKSURL:  server.URL,
+		Issuer:   "https://issuer.test",
+		Audience: "popenstatus",
+		OrgClaim: "o.id",
+	})
+	if err != nil {
+		t.Fatalf("new authenticator: %v", err)
+	}
+	return authenticator
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	key := generateKey(t)
+	other := generateKey(t)
+	server := newJWKSServer(t, key)
+	authenticator := newTestAuthenticator(t, server)
+
+	expired := validClaims()
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "https://attacker.test"
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.Audience{"someone-else"}
+	noSubject := validClaims()
+	noSubject.Subject = ""
+	noExpiry := validClaims()
+	noExpiry.Expiry = nil
+	noAudience := validClaims()
+	noAudience.Audience = nil
+
+	org := map[string]interface{}{"o": map[string]interface{}{"id": "org_1"}}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", signToken(t, key, keyID(key), validClaims(), org), false},
+		{"expired", signToken(t, key, keyID(key), expired, org), true},
+		{"wrong issuer", signToken(t, key, keyID(key), wrongIssuer, org), true},
+		{"wrong audience", signToken(t, key, keyID(key), wrongAudience, org), true},
+		{"no subject", signToken(t, key, keyID(key), noSubject, org), true},
+		{"no expiry", signToken(t, key, keyID(key), noExpiry, org), true},
+		{"no audience", signToken(t, key, keyID(key), noAudience, org), true},
+		{"forged with the key ID of a real key", signToken(t, other, keyID(key), validClaims(), org), true},
+		{"malformed", "not-a-jwt", true},
+	}
+
+	for _, tt := range tests {
+		identity, err := authenticator.Authenticate(context.Background(), tt.token)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("%s: err = %v, want ErrInvalidToken", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if identity.UserID != "user_1" || identity.OrganizationID != "org_1" {
+			t.Errorf("%s: identity = %+v", tt.name, identity)
+		}
+	}
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Errorf("key set fetched %d times, want it cached after the first fetch", fetches)
+	}
+}
+
+func TestNewOIDCAuthenticatorRequiresAnAudience(t *testing.T) {
+	_, err := NewOIDCAuthenticator(OIDCConfig{JWKSURL: "https://issuer.test/jwks.json"})
+	if err == nil {
+		t.Error("NewOIDCAuthenticator accepted a configuration without an audience")
+	}
+}
+
+func TestOIDCUnknownKeyIDsDoNotHammerTheProvider(t *testing.T) {
+	key := generateKey(t)
+	server := newJWKSServer(t, key)
+	authenticator := newTestAuthenticator(t, server)
+
+	// Concurrent requests share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token := signToken(t, key, "made-up-"+string(rune('a'+i%26)), validClaims(), nil)
+			if _, err := authenticator.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("unknown key ID: err = %v, want ErrInvalidToken", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Fatalf("key set fetched %d times for unknown key IDs, want 1", fetches)
+	}
+
+	// A rotated key is picked up once the refetch interval has passed
+	rotated := generateKey(t)
+	server.setKeys(key, rotated)
+	token := signToken(t, rotated, keyID(rotated), validClaims(), nil)
+
+	if _, err := authenticator.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("rotated key within the refetch interval: err = %v, want ErrInvalidToken", err)
+	}
+
+	authenticator.mu.Lock()
+	authenticator.attemptedAt = time.Now().Add(-jwksRefetchInterval)
+	authenticator.mu.Unlock()
+
+	if _, err := authenticator.Authenticate(context.Background(), token); err != nil {
+		t.Fatalf("rotated key after the refetch interval: %v", err)
+	}
+	if fetches := server.fetches.Load(); fetches != 2 {
+		t.Errorf("key set fetched %d times, want 2", fetches)
+	}
+}
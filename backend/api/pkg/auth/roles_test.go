@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+var allRoles = []string{RoleOwner, RoleAdmin, RoleResponder, RoleViewer}
+
+var allScopes = []string{
+	models.ScopeServicesWrite,
+	models.ScopeIncidentsWrite,
+	models.ScopeMaintenancesWrite,
+	models.ScopeMonitorsWrite,
+	models.ScopeSLOsWrite,
+}
+
+// grants lists, for each role, the required roles it satisfies
+var grants = map[string][]string{
+	RoleOwner:     {RoleOwner, RoleAdmin, RoleResponder, RoleViewer},
+	RoleAdmin:     {RoleAdmin, RoleResponder, RoleViewer},
+	RoleResponder: {RoleResponder, RoleViewer},
+	RoleViewer:    {RoleViewer},
+}
+
+func TestNormalizeRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want string
+	}{
+		{"owner", RoleOwner},
+		{"admin", RoleAdmin},
+		{"responder", RoleResponder},
+		{"viewer", RoleViewer},
+		{"org:admin", RoleAdmin},
+		{"ADMIN", RoleAdmin},
+		{"Org:Owner", RoleOwner},
+		{"member", RoleResponder},
+		{"org:member", RoleResponder},
+		{"basic_member", RoleViewer},
+		{"org:billing", RoleViewer},
+		{"", RoleViewer},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRole(tt.role); got != tt.want {
+			t.Errorf("NormalizeRole(%q) = %q, want %q", tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	for _, role := range allRoles {
+		for _, required := range allRoles {
+			want := contains(grants[role], required)
+			if got := HasRole(role, required); got != want {
+				t.Errorf("HasRole(%q, %q) = %v, want %v", role, required, got, want)
+			}
+		}
+	}
+
+	if !HasRole("org:member", RoleResponder) || HasRole("org:member", RoleAdmin) {
+		t.Error("member should hold exactly the responder permissions")
+	}
+	if !HasRole("unknown", RoleViewer) || HasRole("unknown", RoleResponder) {
+		t.Error("unknown roles should hold exactly the viewer permissions")
+	}
+}
+
+func TestAuthorizeMembers(t *testing.T) {
+	for _, required := range allRoles {
+		for _, scope := range append([]string{""}, allScopes...) {
+			for _, role := range allRoles {
+				want := fiber.StatusForbidden
+				if contains(grants[role], required) {
+					want = fiber.StatusOK
+				}
+
+				member := models.OrganizationMember{Role: role}
+				if got := authorizeStatus(t, required, scope, memberKey, member); got != want {
+					t.Errorf("Authorize(%q, %q) for a %s = %d, want %d", required, scope, role, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAuthorizeAPIKeys(t *testing.T) {
+	for _, required := range allRoles {
+		for _, scope := range allScopes {
+			for _, granted := range allScopes {
+				want := fiber.StatusForbidden
+				if granted == scope {
+					want = fiber.StatusOK
+				}
+
+				key := models.APIKey{Scopes: granted}
+				if got := authorizeStatus(t, required, scope, apiKeyKey, key); got != want {
+					t.Errorf("Authorize(%q, %q) for a %s key = %d, want %d", required, scope, granted, got, want)
+				}
+			}
+		}
+
+		// Routes without a scope are for members only
+		key := models.APIKey{Scopes: models.ScopeServicesWrite + "," + models.ScopeIncidentsWrite}
+		if got := authorizeStatus(t, required, "", apiKeyKey, key); got != fiber.StatusForbidden {
+			t.Errorf("RequireRole(%q) for an API key = %d, want %d", required, got, fiber.StatusForbidden)
+		}
+	}
+}
+
+func TestAuthorizeAnonymous(t *testing.T) {
+	for _, required := range allRoles {
+		if got := authorizeStatus(t, required, models.ScopeServicesWrite, "", nil); got != fiber.StatusUnauthorized {
+			t.Errorf("Authorize(%q) without a caller = %d, want %d", required, got, fiber.StatusUnauthorized)
+		}
+	}
+}
+
+// authorizeStatus runs a request through Authorize with the given caller
+// stored in the request locals, as Middleware would, and returns its status
+func authorizeStatus(t *testing.T, required string, scope string, localsKey string, caller interface{}) int {
+	t.Helper()
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		if localsKey != "" {
+			c.Locals(localsKey, caller)
+		}
+		return c.Next()
+	}, Authorize(required, scope), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/subscribers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// postTimeout bounds a single post to a chat provider
+	postTimeout = 10 * time.Second
+	// maxConcurrentPosts bounds how many posts are sent at the same time
+	maxConcurrentPosts = 10
+	// maxAttempts is how many times a post is tried before it is dropped
+	maxAttempts = 3
+	// initialBackoff is the delay before the first retry, doubled for every further one
+	initialBackoff = 2 * time.Second
+)
+
+// Dispatcher posts the events of an organization to its enabled chat
+// integrations that chose the event's kind and services
+type Dispatcher struct {
+	db      *gorm.DB
+	client  *http.Client
+	sem     chan struct{}
+	backoff time.Duration
+}
+
+func NewDispatcher(database *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		db:      database,
+		client:  utils.NewOutboundClient(postTimeout),
+		sem:     make(chan struct{}, maxConcurrentPosts),
+		backoff: initialBackoff,
+	}
+}
+
+// Start posts every published event until the context is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	events.AddListener(func(slug string, event events.Event) {
+		if err := d.Dispatch(ctx, slug, event); err != nil {
+			log.Printf("chat: failed to post %s event: %v", event.Type, err)
+		}
+	})
+}
+
+// Dispatch composes the message about the event and posts it in the
+// background to every matching integration of the organization
+func (d *Dispatcher) Dispatch(ctx context.Context, slug string, event events.Event) error {
+	var org models.Organization
+	if err := d.db.Where("slug = ?", slug).First(&org).Error; err != nil {
+		return err
+	}
+
+	key, message, err := d.compose(org, event)
+	if err != nil || key == "" {
+		return err
+	}
+
+	var integrations []models.ChatIntegration
+	if err := d.db.Where("organization_id = ? AND enabled = ?", org.ID, true).Find(&integrations).Error; err != nil {
+		return err
+	}
+
+	for _, integration := range integrations {
+		if !events.ParsePreferences(integration.ServiceIDs, integration.EventKinds).Matches(event) {
+			continue
+		}
+
+		// Only the first event about a change claims its key
+		post := models.ChatPost{IntegrationID: integration.ID, DedupKey: key}
+		result := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&post)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		go func(integration models.ChatIntegration) {
+			d.sem <- struct{}{}
+			defer func() { <-d.sem }()
+
+			if err := d.Post(ctx, integration, message); err != nil {
+				log.Printf("chat: failed to post to integration %d: %v", integration.ID, err)
+			}
+		}(integration)
+	}
+
+	return nil
+}
+
+// Post sends the message to the integration, retrying failed attempts, and
+// records the outcome on the integration
+func (d *Dispatcher) Post(ctx context.Context, integration models.ChatIntegration, message Message) error {
+	notifier, err := NewNotifier(integration.Provider, integration.WebhookURL, d.client)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = notifier.Notify(ctx, message); err == nil {
+			break
+		}
+		if attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.backoff << (attempt - 1)):
+		}
+	}
+
+	now := time.Now()
+	outcome := map[string]interface{}{"last_posted_at": now, "last_error": ""}
+	if err != nil {
+		outcome = map[string]interface{}{"last_error": err.Error()}
+	}
+	if updateErr := d.db.Model(&integration).Updates(outcome).Error; updateErr != nil {
+		log.Printf("chat: failed to record outcome of integration %d: %v", integration.ID, updateErr)
+	}
+
+	return err
+}
+
+// compose returns the deduplication key and the message about an event, with
+// an empty key for events chat channels are not told about
+func (d *Dispatcher) compose(org models.Organization, event events.Event) (string, Message, error) {
+	switch {
+	case event.Incident != nil && event.Action != "deleted":
+		incident := event.Incident
+		key := fmt.Sprintf("incident:%d:%s", incident.ID, event.Action)
+		message := Message{Status: incident.Status, URL: subscribers.StatusPageURL(org)}
+
+		// Saving an incident without posting an update publishes it again
+		// with the same latest update, which is then not posted twice
+		var update models.IncidentUpdate
+		err := d.db.Where("incident_id = ?", fmt.Sprint(incident.ID)).
+			Order("timestamp DESC, id DESC").First(&update).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", Message{}, err
+		}
+		if err == nil {
+			key = fmt.Sprintf("incident_update:%d", update.ID)
+			message.Status = update.Status
+			message.Text = update.Message
+		}
+
+		switch {
+		case event.Action == "created":
+			message.Title = "New incident: " + incident.Title
+		case event.Action == "reopened":
+			message.Title = "Reopened: " + incident.Title
+		case message.Status == models.IncidentStatusResolved:
+			message.Title = "Resolved: " + incident.Title
+		default:
+			message.Title = "Update: " + incident.Title
+		}
+
+		names, err := d.serviceNames(event.ServiceIDs())
+		if err != nil {
+			return "", Message{}, err
+		}
+		message.Services = names
+		return key, message, nil
+
+	case event.Maintenance != nil:
+		maintenance := event.Maintenance
+		message := Message{Status: maintenance.Status, URL: subscribers.StatusPageURL(org)}
+		switch event.Action {
+		case "created":
+			message.Title = "Scheduled maintenance: " + maintenance.Title
+			message.Text = fmt.Sprintf("%s\nFrom %s to %s", maintenance.Description,
+				maintenance.ScheduledStart.UTC().Format(time.RFC1123), maintenance.ScheduledEnd.UTC().Format(time.RFC1123))
+		case "started":
+			message.Title = "Maintenance in progress: " + maintenance.Title
+		case "completed":
+			message.Title = "Maintenance completed: " + maintenance.Title
+		case "cancelled":
+			message.Title = "Maintenance cancelled: " + maintenance.Title
+		default:
+			return "", Message{}, nil
+		}
+
+		names, err := d.serviceNames(event.ServiceIDs())
+		if err != nil {
+			return "", Message{}, err
+		}
+		message.Services = names
+		return fmt.Sprintf("maintenance:%d:%s", maintenance.ID, event.Action), message, nil
+
+	case event.SLOAlert != nil:
+		alert := event.SLOAlert
+		message := Message{Status: event.Action, URL: subscribers.StatusPageURL(org)}
+		if event.Action == models.SLOAlertResolved {
+			message.Title = "SLO burn rate back to normal: " + alert.SLO.Name
+		} else {
+			message.Title = "SLO burning too fast: " + alert.SLO.Name
+			message.Text = fmt.Sprintf("The error budget burns %.1f times faster than sustainable, the threshold is %.1f",
+				alert.BurnRate, alert.Threshold)
+		}
+
+		names, err := d.serviceNames(event.ServiceIDs())
+		if err != nil {
+			return "", Message{}, err
+		}
+		message.Services = names
+		return fmt.Sprintf("slo_alert:%d:%s", alert.ID, event.Action), message, nil
+
+	case event.Type == events.TypeStatusChange:
+		// Status changes are posted through the recorded transition, so
+		// saving a service without changing its status posts nothing new
+		var change models.ServiceStatusChange
+		err := d.db.Where("service_id = ?", event.Service).
+			Order("changed_at DESC, id DESC").First(&change).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", Message{}, nil
+		}
+		if err != nil {
+			return "", Message{}, err
+		}
+
+		var service models.Service
+		if err := d.db.Where("id = ?", event.Service).First(&service).Error; err != nil {
+			return "", Message{}, err
+		}
+
+		return fmt.Sprintf("status_change:%d", change.ID), Message{
+			Title:    fmt.Sprintf("%s: %s", service.Name, utils.Humanize(change.NewStatus)),
+			Text:     change.Reason,
+			Status:   change.NewStatus,
+			Services: []string{service.Name},
+			URL:      subscribers.StatusPageURL(org),
+		}, nil
+	}
+
+	return "", Message{}, nil
+}
+
+// serviceNames returns the names of the services with the given IDs
+func (d *Dispatcher) serviceNames(serviceIDs []string) ([]string, error) {
+	if len(serviceIDs) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	err := d.db.Model(&models.Service{}).Where("id IN ?", serviceIDs).Order("name ASC").Pluck("name", &names).Error
+	return names, err
+}
@@ -0,0 +1,310 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/subscribers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CreateIntegrationRequest struct {
+	Provider   string   `json:"provider" validate:"required,oneof=slack discord teams"`
+	Name       string   `json:"name"`
+	WebhookURL string   `json:"webhook_url" validate:"required,url"`
+	ServiceIDs []string `json:"service_ids"` // Empty for every service
+	EventKinds []string `json:"event_kinds"` // Defaults to incidents and status changes
+}
+
+type UpdateIntegrationRequest struct {
+	Name       string   `json:"name"`
+	WebhookURL string   `json:"webhook_url" validate:"omitempty,url"`
+	ServiceIDs []string `json:"service_ids"` // An empty list selects every service again
+	EventKinds []string `json:"event_kinds"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// IntegrationResponse describes an integration without its webhook URL
+type IntegrationResponse struct {
+	ID           uint       `json:"id"`
+	Provider     string     `json:"provider"`
+	Name         string     `json:"name"`
+	ServiceIDs   []string   `json:"service_ids"`
+	EventKinds   []string   `json:"event_kinds"`
+	Enabled      bool       `json:"enabled"`
+	LastPostedAt *time.Time `json:"last_posted_at"`
+	LastError    string     `json:"last_error"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// defaultEventKinds are the kinds an integration that picks none is posted
+var defaultEventKinds = []string{events.KindIncidents, events.KindStatusChanges}
+
+func newIntegrationResponse(integration models.ChatIntegration) IntegrationResponse {
+	preferences := events.ParsePreferences(integration.ServiceIDs, integration.EventKinds)
+	if preferences.ServiceIDs == nil {
+		preferences.ServiceIDs = []string{}
+	}
+
+	return IntegrationResponse{
+		ID:           integration.ID,
+		Provider:     integration.Provider,
+		Name:         integration.Name,
+		ServiceIDs:   preferences.ServiceIDs,
+		EventKinds:   preferences.Kinds,
+		Enabled:      integration.Enabled,
+		LastPostedAt: integration.LastPostedAt,
+		LastError:    integration.LastError,
+		CreatedAt:    integration.CreatedAt,
+	}
+}
+
+// CreateIntegration connects a chat channel of the organization through its
+// incoming webhook URL
+func CreateIntegration(c *fiber.Ctx) error {
+	var req CreateIntegrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	if err := utils.CheckURL(req.WebhookURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	if len(req.EventKinds) == 0 {
+		req.EventKinds = defaultEventKinds
+	}
+	if err := checkPreferences(database, org.ID, req.ServiceIDs, req.EventKinds); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	integration := models.ChatIntegration{
+		Provider:       req.Provider,
+		Name:           req.Name,
+		WebhookURL:     req.WebhookURL,
+		Enabled:        true,
+		OrganizationID: org.ID,
+		ServiceIDs:     strings.Join(req.ServiceIDs, ","),
+		EventKinds:     strings.Join(req.EventKinds, ","),
+	}
+
+	if err := database.Create(&integration).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create chat integration",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newIntegrationResponse(integration))
+}
+
+// ListIntegrations lists the organization's chat integrations
+func ListIntegrations(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var integrations []models.ChatIntegration
+	if err := database.Where("organization_id = ?", org.ID).Order("created_at ASC").Find(&integrations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch chat integrations",
+		})
+	}
+
+	response := make([]IntegrationResponse, 0, len(integrations))
+	for _, integration := range integrations {
+		response = append(response, newIntegrationResponse(integration))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// UpdateIntegration changes the name, webhook URL, services, event kinds or
+// enabled state of an integration of the organization
+func UpdateIntegration(c *fiber.Ctx) error {
+	var req UpdateIntegrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	integration, err := findIntegration(database, auth.OrganizationID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Update the integration fields if they are provided
+	if req.Name != "" {
+		integration.Name = req.Name
+	}
+	if req.WebhookURL != "" {
+		if err := utils.CheckURL(req.WebhookURL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		integration.WebhookURL = req.WebhookURL
+	}
+	if req.ServiceIDs != nil {
+		if err := services.CheckServiceIDs(database, integration.OrganizationID, req.ServiceIDs); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		integration.ServiceIDs = strings.Join(req.ServiceIDs, ",")
+	}
+	if len(req.EventKinds) > 0 {
+		if err := events.ValidateKinds(req.EventKinds); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		integration.EventKinds = strings.Join(req.EventKinds, ",")
+	}
+	if req.Enabled != nil {
+		integration.Enabled = *req.Enabled
+	}
+
+	if err := database.Save(&integration).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update chat integration",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newIntegrationResponse(integration))
+}
+
+// DeleteIntegration disconnects a chat channel of the organization
+func DeleteIntegration(c *fiber.Ctx) error {
+	integration, err := findIntegration(db.GetDB(), auth.OrganizationID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := db.GetDB().Delete(&integration).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete chat integration",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Chat integration deleted successfully",
+	})
+}
+
+// TestIntegration posts a sample message to a chat channel of the
+// organization, so admins can check the webhook URL right away
+func TestIntegration(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	integration, err := findIntegration(database, auth.OrganizationID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var org models.Organization
+	if err := database.Where("id = ?", integration.OrganizationID).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	notifier, err := NewNotifier(integration.Provider, integration.WebhookURL, utils.NewOutboundClient(postTimeout))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	err = notifier.Notify(c.Context(), Message{
+		Title:  "Test message from " + org.Name,
+		Text:   "Incidents and status changes will be posted here.",
+		Status: models.ServiceStatusOperational,
+		URL:    subscribers.StatusPageURL(org),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   "Failed to post to the chat channel",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Test message posted",
+	})
+}
+
+// findIntegration loads an integration of the organization
+func findIntegration(database *gorm.DB, clerkOrgID string, integrationID string) (models.ChatIntegration, error) {
+	var integration models.ChatIntegration
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return integration, errors.New("Organization not found")
+	}
+
+	if err := database.Where("id = ? AND organization_id = ?", integrationID, org.ID).First(&integration).Error; err != nil {
+		return integration, errors.New("Chat integration not found or does not belong to the organization")
+	}
+
+	return integration, nil
+}
+
+// checkPreferences checks that the event kinds are known and the services
+// belong to the organization
+func checkPreferences(database *gorm.DB, orgID string, serviceIDs []string, eventKinds []string) error {
+	if err := events.ValidateKinds(eventKinds); err != nil {
+		return err
+	}
+	return services.CheckServiceIDs(database, orgID, serviceIDs)
+}
@@ -0,0 +1,235 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+)
+
+// Message is a chat post about an incident, maintenance, service status or SLO alert
+type Message struct {
+	Title    string   // e.g., "New incident: Database outage"
+	Text     string   // Latest update, may be empty
+	Status   string   // Incident, maintenance, service or SLO alert status, picks the color
+	Services []string // Names of the affected services
+	URL      string   // Status page
+}
+
+// Notifier posts messages to a chat channel
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+// NewNotifier returns the notifier posting to an incoming webhook of the provider
+func NewNotifier(provider string, webhookURL string, client *http.Client) (Notifier, error) {
+	switch provider {
+	case models.ChatProviderSlack:
+		return &SlackNotifier{WebhookURL: webhookURL, Client: client}, nil
+	case models.ChatProviderDiscord:
+		return &DiscordNotifier{WebhookURL: webhookURL, Client: client}, nil
+	case models.ChatProviderTeams:
+		return &TeamsNotifier{WebhookURL: webhookURL, Client: client}, nil
+	}
+	return nil, fmt.Errorf("Unknown chat provider %s", provider)
+}
+
+// Colors of the statuses, from operational green to major outage red
+var statusColors = map[string]int{
+	models.ServiceStatusOperational:      0x2EB67D,
+	models.IncidentStatusResolved:        0x2EB67D,
+	"completed":                          0x2EB67D,
+	models.ServiceStatusDegraded:         0xECB22E,
+	models.IncidentStatusMonitoring:      0xECB22E,
+	models.ServiceStatusPartialOutage:    0xF2952F,
+	models.IncidentStatusIdentified:      0xF2952F,
+	models.ServiceStatusMajorOutage:      0xE01E5A,
+	models.IncidentStatusInvestigating:   0xE01E5A,
+	models.ServiceStatusUnderMaintenance: 0x1D9BD1,
+	"scheduled":                          0x1D9BD1,
+	"in_progress":                        0x1D9BD1,
+	models.SLOAlertTriggered:             0xF2952F,
+}
+
+// defaultColor is used for statuses without a color of their own, such as
+// cancelled maintenances
+const defaultColor = 0x868686
+
+// StatusColor returns the RGB color of a status
+func StatusColor(status string) int {
+	if color, ok := statusColors[status]; ok {
+		return color
+	}
+	return defaultColor
+}
+
+// SlackNotifier posts Block Kit messages to a Slack incoming webhook
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Notify posts the message to the Slack channel
+func (n *SlackNotifier) Notify(ctx context.Context, message Message) error {
+	fields := []map[string]interface{}{
+		{"type": "mrkdwn", "text": "*Status*\n" + utils.Humanize(message.Status)},
+	}
+	if len(message.Services) > 0 {
+		fields = append(fields, map[string]interface{}{
+			"type": "mrkdwn", "text": "*Affected services*\n" + strings.Join(message.Services, ", "),
+		})
+	}
+
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": message.Title}},
+		{"type": "section", "fields": fields},
+	}
+	if message.Text != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": message.Text},
+		})
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type": "actions",
+		"elements": []map[string]interface{}{{
+			"type": "button",
+			"text": map[string]interface{}{"type": "plain_text", "text": "View status page"},
+			"url":  message.URL,
+		}},
+	})
+
+	// The attachment only adds the colored bar next to the blocks
+	return post(ctx, n.Client, n.WebhookURL, map[string]interface{}{
+		"text": message.Title,
+		"attachments": []map[string]interface{}{{
+			"color":  hexColor(StatusColor(message.Status)),
+			"blocks": blocks,
+		}},
+	})
+}
+
+// DiscordNotifier posts embeds to a Discord channel webhook
+type DiscordNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Notify posts the message to the Discord channel
+func (n *DiscordNotifier) Notify(ctx context.Context, message Message) error {
+	fields := []map[string]interface{}{
+		{"name": "Status", "value": utils.Humanize(message.Status), "inline": true},
+	}
+	if len(message.Services) > 0 {
+		fields = append(fields, map[string]interface{}{
+			"name": "Affected services", "value": strings.Join(message.Services, ", "), "inline": true,
+		})
+	}
+
+	embed := map[string]interface{}{
+		"title":  message.Title,
+		"url":    message.URL,
+		"color":  StatusColor(message.Status),
+		"fields": fields,
+	}
+	if message.Text != "" {
+		embed["description"] = message.Text
+	}
+
+	return post(ctx, n.Client, n.WebhookURL, map[string]interface{}{
+		"embeds": []map[string]interface{}{embed},
+	})
+}
+
+// TeamsNotifier posts Adaptive Cards to a Microsoft Teams incoming webhook
+type TeamsNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Adaptive Cards only offer named colors
+var teamsColors = map[int]string{
+	0x2EB67D: "Good",
+	0xECB22E: "Warning",
+	0xF2952F: "Warning",
+	0xE01E5A: "Attention",
+	0x1D9BD1: "Accent",
+}
+
+// Notify posts the message to the Teams channel
+func (n *TeamsNotifier) Notify(ctx context.Context, message Message) error {
+	color, ok := teamsColors[StatusColor(message.Status)]
+	if !ok {
+		color = "Default"
+	}
+
+	facts := []map[string]interface{}{
+		{"title": "Status", "value": utils.Humanize(message.Status)},
+	}
+	if len(message.Services) > 0 {
+		facts = append(facts, map[string]interface{}{
+			"title": "Affected services", "value": strings.Join(message.Services, ", "),
+		})
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": message.Title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+		{"type": "FactSet", "facts": facts},
+	}
+	if message.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": message.Text, "wrap": true})
+	}
+
+	return post(ctx, n.Client, n.WebhookURL, map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+				"actions": []map[string]interface{}{
+					{"type": "Action.OpenUrl", "title": "View status page", "url": message.URL},
+				},
+			},
+		}},
+	})
+}
+
+// post sends the payload as JSON to an incoming webhook
+func post(ctx context.Context, client *http.Client, webhookURL string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PopenStatus-Chat")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func hexColor(color int) string {
+	return fmt.Sprintf("#%06X", color)
+}
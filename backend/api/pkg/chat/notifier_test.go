@@ -0,0 +1,157 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+)
+
+// webhookRequest is a post received by the incoming webhook stand-in
+type webhookRequest struct {
+	ContentType string
+	Payload     map[string]interface{}
+	Raw         string
+}
+
+// startWebhook runs an incoming webhook stand-in answering with the status
+func startWebhook(t *testing.T, status int) (string, <-chan webhookRequest) {
+	t.Helper()
+
+	received := make(chan webhookRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		request := webhookRequest{ContentType: r.Header.Get("Content-Type"), Raw: string(body)}
+		if err := json.Unmarshal(body, &request.Payload); err != nil {
+			t.Errorf("payload is not JSON: %v", err)
+		}
+		received <- request
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, received
+}
+
+var incidentMessage = Message{
+	Title:    "New incident: Database outage",
+	Text:     "We are looking into it.",
+	Status:   models.IncidentStatusInvestigating,
+	Services: []string{"API", "Web"},
+	URL:      "https://status.acme.test",
+}
+
+func TestNotifiersPostToTheirWebhooks(t *testing.T) {
+	tests := []struct {
+		provider string
+		check    func(t *testing.T, payload map[string]interface{})
+	}{
+		{models.ChatProviderSlack, func(t *testing.T, payload map[string]interface{}) {
+			if payload["text"] != incidentMessage.Title {
+				t.Errorf("text = %v", payload["text"])
+			}
+			attachment := payload["attachments"].([]interface{})[0].(map[string]interface{})
+			if attachment["color"] != "#E01E5A" {
+				t.Errorf("color = %v, want the investigating red", attachment["color"])
+			}
+			if blocks := attachment["blocks"].([]interface{}); len(blocks) != 4 {
+				t.Errorf("got %d blocks, want header, fields, text and button", len(blocks))
+			}
+		}},
+		{models.ChatProviderDiscord, func(t *testing.T, payload map[string]interface{}) {
+			embed := payload["embeds"].([]interface{})[0].(map[string]interface{})
+			if embed["title"] != incidentMessage.Title || embed["url"] != incidentMessage.URL {
+				t.Errorf("embed = %v", embed)
+			}
+			if embed["color"] != float64(0xE01E5A) {
+				t.Errorf("color = %v, want the investigating red", embed["color"])
+			}
+			if embed["description"] != incidentMessage.Text {
+				t.Errorf("description = %v", embed["description"])
+			}
+		}},
+		{models.ChatProviderTeams, func(t *testing.T, payload map[string]interface{}) {
+			attachment := payload["attachments"].([]interface{})[0].(map[string]interface{})
+			if attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+				t.Errorf("contentType = %v", attachment["contentType"])
+			}
+			card := attachment["content"].(map[string]interface{})
+			title := card["body"].([]interface{})[0].(map[string]interface{})
+			if title["text"] != incidentMessage.Title || title["color"] != "Attention" {
+				t.Errorf("title block = %v", title)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			url, received := startWebhook(t, http.StatusOK)
+
+			notifier, err := NewNotifier(tt.provider, url, http.DefaultClient)
+			if err != nil {
+				t.Fatalf("NewNotifier: %v", err)
+			}
+			if err := notifier.Notify(context.Background(), incidentMessage); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+
+			request := <-received
+			if request.ContentType != "application/json" {
+				t.Errorf("Content-Type = %q", request.ContentType)
+			}
+			for _, want := range []string{"Investigating", "API, Web"} {
+				if !strings.Contains(request.Raw, want) {
+					t.Errorf("payload is missing %q: %s", want, request.Raw)
+				}
+			}
+			tt.check(t, request.Payload)
+		})
+	}
+}
+
+func TestNotifiersReportRejectedPosts(t *testing.T) {
+	for _, provider := range []string{models.ChatProviderSlack, models.ChatProviderDiscord, models.ChatProviderTeams} {
+		url, _ := startWebhook(t, http.StatusNotFound)
+
+		notifier, err := NewNotifier(provider, url, http.DefaultClient)
+		if err != nil {
+			t.Fatalf("NewNotifier(%s): %v", provider, err)
+		}
+		if err := notifier.Notify(context.Background(), incidentMessage); err == nil {
+			t.Errorf("%s: Notify succeeded although the webhook answered 404", provider)
+		}
+	}
+}
+
+func TestDispatcherRefusesPrivateWebhooks(t *testing.T) {
+	url, received := startWebhook(t, http.StatusOK)
+
+	notifier, err := NewNotifier(models.ChatProviderSlack, url, NewDispatcher(nil).client)
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), incidentMessage); !errors.Is(err, utils.ErrPrivateAddress) {
+		t.Errorf("posting to a loopback webhook: err = %v, want ErrPrivateAddress", err)
+	}
+
+	select {
+	case <-received:
+		t.Error("a post reached the loopback webhook")
+	default:
+	}
+}
+
+func TestNewNotifierRejectsUnknownProviders(t *testing.T) {
+	if _, err := NewNotifier("irc", "https://example.com", nil); err == nil {
+		t.Error("NewNotifier accepted an unknown provider")
+	}
+}
@@ -44,9 +44,25 @@ func Connect() *gorm.DB {
 	// 	&models.Organization{},
 	// 	&models.Service{},
 	// 	&models.Incident{},
+	// 	&models.IncidentService{},
 	// 	&models.IncidentUpdate{},
 	// 	&models.Maintenance{},
 	// 	&models.OrganizationMember{},
+	// 	&models.OrganizationInvitation{},
+	// 	&models.Monitor{},
+	// 	&models.MonitorCheckResult{},
+	// 	&models.ServiceStatusChange{},
+	// 	&models.ServiceUptimeDaily{},
+	// 	&models.SLO{},
+	// 	&models.SLOAlert{},
+	// 	&models.APIKey{},
+	// 	&models.WebhookEvent{},
+	// 	&models.WebhookEndpoint{},
+	// 	&models.WebhookDelivery{},
+	// 	&models.Subscriber{},
+	// 	&models.SubscriberNotification{},
+	// 	&models.ChatIntegration{},
+	// 	&models.ChatPost{},
 	// )
 
 	// // Apply schema to the DB
@@ -54,9 +70,25 @@ func Connect() *gorm.DB {
 	// 	&models.Organization{},
 	// 	&models.Service{},
 	// 	&models.Incident{},
+	// 	&models.IncidentService{},
 	// 	&models.IncidentUpdate{},
 	// 	&models.Maintenance{},
 	// 	&models.OrganizationMember{},
+	// 	&models.OrganizationInvitation{},
+	// 	&models.Monitor{},
+	// 	&models.MonitorCheckResult{},
+	// 	&models.ServiceStatusChange{},
+	// 	&models.ServiceUptimeDaily{},
+	// 	&models.SLO{},
+	// 	&models.SLOAlert{},
+	// 	&models.APIKey{},
+	// 	&models.WebhookEvent{},
+	// 	&models.WebhookEndpoint{},
+	// 	&models.WebhookDelivery{},
+	// 	&models.Subscriber{},
+	// 	&models.SubscriberNotification{},
+	// 	&models.ChatIntegration{},
+	// 	&models.ChatPost{},
 	// )
 	// if err != nil {
 	// 	panic("failed to migrate schema: " + err.Error())
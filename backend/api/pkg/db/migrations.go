@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// MigrateIncidentServices moves the single service of incidents created before
+// incidents could affect several services into the incident_services table.
+// It does nothing once the incidents table no longer has a service_id column.
+func MigrateIncidentServices(database *gorm.DB) error {
+	migrator := database.Migrator()
+	if !migrator.HasTable(&models.Incident{}) || !migrator.HasColumn(&models.Incident{}, "service_id") {
+		return nil
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.IncidentService{}); err != nil {
+			return err
+		}
+
+		// The impact is derived from the severity, the same way monitors pick it
+		if err := tx.Exec(`
+			INSERT INTO incident_services (incident_id, service_id, impact)
+			SELECT id, service_id,
+				CASE severity
+					WHEN 'critical' THEN 'major_outage'
+					WHEN 'high' THEN 'partial_outage'
+					WHEN 'medium' THEN 'degraded'
+					ELSE 'partial_outage'
+				END
+			FROM incidents
+			WHERE service_id IS NOT NULL AND service_id <> ''
+			ON CONFLICT (incident_id, service_id) DO NOTHING`).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Migrator().DropColumn(&models.Incident{}, "service_id"); err != nil {
+			return err
+		}
+
+		fmt.Println("✅ Migrated incident services")
+		return nil
+	})
+}
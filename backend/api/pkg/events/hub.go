@@ -0,0 +1,337 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+)
+
+// Event types pushed to real-time clients
+const (
+	TypeStatusChange      = "statusChange"
+	TypeIncidentUpdate    = "incidentUpdate"
+	TypeMaintenanceUpdate = "maintenanceUpdate"
+	TypeSLOAlert          = "sloAlert"
+)
+
+// Event is a single real-time update for an organization. Only the fields
+// relevant to the event type are set.
+type Event struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// statusChange
+	Service string `json:"service,omitempty"` // service ID
+	Status  string `json:"status,omitempty"`
+
+	// incidentUpdate, maintenanceUpdate and sloAlert
+	Action      string              `json:"action,omitempty"` // e.g., created/updated/deleted
+	Incident    *models.Incident    `json:"incident,omitempty"`
+	Maintenance *models.Maintenance `json:"maintenance,omitempty"`
+	SLOAlert    *models.SLOAlert    `json:"slo_alert,omitempty"`
+}
+
+// Subscriber receives the events published for a single organization. Events
+// is closed once the subscriber is unsubscribed or falls too far behind.
+type Subscriber struct {
+	Events chan Event
+	slug   string
+}
+
+// Hub fans out events to subscribers grouped by organization slug and keeps
+// the most recent events of each organization so clients can resume
+type Hub struct {
+	epoch       string // Tells the IDs of this process apart from those of earlier ones
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscriber]struct{}
+	lastID      uint64
+	history     map[string][]Event
+	trimmedID   map[string]uint64 // ID of the newest event dropped from history
+	listeners   []*listenerQueue
+}
+
+// Listener is called with every event published on a hub, for consumers that
+// must not miss events the way slow subscribers do. Each listener runs on its
+// own goroutine and gets the events one at a time, in the order of their IDs.
+type Listener func(slug string, event Event)
+
+// publishedEvent is an event waiting for a listener
+type publishedEvent struct {
+	slug  string
+	event Event
+}
+
+// listenerQueue holds the events a listener has yet to handle. It grows as
+// needed, so queueing never waits, not even for a listener that publishes.
+type listenerQueue struct {
+	mu      sync.Mutex
+	pending []publishedEvent
+	wake    chan struct{}
+}
+
+func (q *listenerQueue) push(published publishedEvent) {
+	q.mu.Lock()
+	q.pending = append(q.pending, published)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run hands the queued events to the listener, oldest first
+func (q *listenerQueue) run(listener Listener) {
+	for range q.wake {
+		q.mu.Lock()
+		batch := q.pending
+		q.pending = nil
+		q.mu.Unlock()
+
+		for _, published := range batch {
+			listener(published.slug, published.event)
+		}
+	}
+}
+
+// subscriberBuffer is how many events a slow client can lag behind before it
+// is unsubscribed, so that it reconnects and resumes from the history
+const subscriberBuffer = 32
+
+// historySize is how many events are kept per organization for resuming clients
+const historySize = 256
+
+var defaultHub = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		subscribers: make(map[string]map[*Subscriber]struct{}),
+		history:     make(map[string][]Event),
+		trimmedID:   make(map[string]uint64),
+	}
+}
+
+// Subscribe registers a new subscriber for the organization slug
+func (h *Hub) Subscribe(slug string) *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(slug)
+}
+
+// Resume registers a new subscriber and returns the events published for the
+// organization after the one with the given client-facing ID. complete is
+// false when some of those events are no longer in the history, or the ID
+// was handed out by another process, in which case the client has to start
+// over from a fresh snapshot.
+func (h *Hub) Resume(slug string, lastEventID string) (sub *Subscriber, missed []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = h.subscribe(slug)
+
+	lastID, ok := h.parseEventID(lastEventID)
+	if !ok || lastID > h.lastID || lastID < h.trimmedID[slug] {
+		return sub, nil, false
+	}
+
+	for _, event := range h.history[slug] {
+		if event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+
+	return sub, missed, true
+}
+
+// LastID returns the ID of the most recently published event
+func (h *Hub) LastID() uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.lastID
+}
+
+// EventID returns the ID clients see for an event ID of the hub. It is
+// prefixed with the hub's epoch, as event IDs start over with every process.
+func (h *Hub) EventID(id uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(id, 10)
+}
+
+// parseEventID returns the event ID of a client-facing ID handed out by this hub
+func (h *Hub) parseEventID(eventID string) (uint64, bool) {
+	epoch, id, found := strings.Cut(eventID, "-")
+	if !found || epoch != h.epoch {
+		return 0, false
+	}
+
+	lastID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return lastID, true
+}
+
+func (h *Hub) subscribe(slug string) *Subscriber {
+	sub := &Subscriber{
+		Events: make(chan Event, subscriberBuffer),
+		slug:   slug,
+	}
+
+	if h.subscribers[slug] == nil {
+		h.subscribers[slug] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[slug][sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unsubscribe(sub)
+}
+
+func (h *Hub) unsubscribe(sub *Subscriber) {
+	subs, ok := h.subscribers[sub.slug]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.slug)
+	}
+	close(sub.Events)
+}
+
+// Publish sends the event to every subscriber of the organization slug and
+// queues it for the listeners. Subscribers whose buffer is full are
+// unsubscribed rather than silently skipped: their channel is closed after
+// the events already buffered, and they resume from the history. Listeners
+// never miss events and Publish never waits for them.
+func (h *Hub) Publish(slug string, event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event.ID = h.lastID
+
+	history := append(h.history[slug], event)
+	if len(history) > historySize {
+		h.trimmedID[slug] = history[len(history)-historySize-1].ID
+		history = history[len(history)-historySize:]
+	}
+	h.history[slug] = history
+
+	for sub := range h.subscribers[slug] {
+		select {
+		case sub.Events <- event:
+		default:
+			h.unsubscribe(sub)
+		}
+	}
+
+	// Queued under the lock, so that every listener gets the events in order
+	for _, queue := range h.listeners {
+		queue.push(publishedEvent{slug: slug, event: event})
+	}
+}
+
+// AddListener registers a listener for every event published from now on
+func (h *Hub) AddListener(listener Listener) {
+	queue := &listenerQueue{wake: make(chan struct{}, 1)}
+	go queue.run(listener)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.listeners = append(h.listeners, queue)
+}
+
+// Subscribe registers a subscriber on the default hub
+func Subscribe(slug string) *Subscriber {
+	return defaultHub.Subscribe(slug)
+}
+
+// Unsubscribe removes a subscriber from the default hub
+func Unsubscribe(sub *Subscriber) {
+	defaultHub.Unsubscribe(sub)
+}
+
+// Resume registers a subscriber on the default hub and returns the events it missed
+func Resume(slug string, lastEventID string) (*Subscriber, []Event, bool) {
+	return defaultHub.Resume(slug, lastEventID)
+}
+
+// EventID returns the ID clients see for an event ID of the default hub
+func EventID(id uint64) string {
+	return defaultHub.EventID(id)
+}
+
+// LastID returns the ID of the most recent event of the default hub
+func LastID() uint64 {
+	return defaultHub.LastID()
+}
+
+// AddListener registers a listener on the default hub
+func AddListener(listener Listener) {
+	defaultHub.AddListener(listener)
+}
+
+// Publish sends an event through the default hub
+func Publish(slug string, event Event) {
+	defaultHub.Publish(slug, event)
+}
+
+// PublishStatusChange notifies subscribers that a service's status was saved
+func PublishStatusChange(slug string, service models.Service) {
+	Publish(slug, Event{
+		Type:    TypeStatusChange,
+		Service: fmt.Sprint(service.ID),
+		Status:  service.Status,
+	})
+}
+
+// PublishIncident notifies subscribers that an incident was created, updated or deleted
+func PublishIncident(slug string, action string, incident models.Incident) {
+	Publish(slug, Event{
+		Type:     TypeIncidentUpdate,
+		Action:   action,
+		Incident: &incident,
+	})
+}
+
+// PublishSLOAlert notifies subscribers that an SLO alert was triggered or
+// resolved. The alert must be published with its SLO loaded.
+func PublishSLOAlert(slug string, action string, alert models.SLOAlert) {
+	Publish(slug, Event{
+		Type:     TypeSLOAlert,
+		Action:   action,
+		SLOAlert: &alert,
+	})
+}
+
+// PublishMaintenance notifies subscribers that a maintenance was scheduled, changed or cancelled
+func PublishMaintenance(slug string, action string, maintenance models.Maintenance) {
+	Publish(slug, Event{
+		Type:        TypeMaintenanceUpdate,
+		Action:      action,
+		Maintenance: &maintenance,
+	})
+}
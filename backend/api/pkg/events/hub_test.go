@@ -0,0 +1,152 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishDoesNotWaitForListeners(t *testing.T) {
+	hub := NewHub()
+
+	release := make(chan struct{})
+	received := make(chan Event, 10)
+	hub.AddListener(func(slug string, event Event) {
+		<-release
+		received <- event
+	})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			hub.Publish("acme", Event{Type: TypeStatusChange})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish waited for a slow listener")
+	}
+
+	close(release)
+	for want := uint64(1); want <= 5; want++ {
+		select {
+		case event := <-received:
+			if event.ID != want {
+				t.Fatalf("listener got event %d, want %d", event.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("listener never got event %d", want)
+		}
+	}
+}
+
+func TestListenersGetConcurrentEventsInOrder(t *testing.T) {
+	hub := NewHub()
+
+	const publishers, perPublisher = 8, 200
+	received := make(chan Event, publishers*perPublisher)
+	hub.AddListener(func(slug string, event Event) {
+		received <- event
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perPublisher; j++ {
+				hub.Publish("acme", Event{Type: TypeStatusChange})
+			}
+		}()
+	}
+	wg.Wait()
+
+	for want := uint64(1); want <= publishers*perPublisher; want++ {
+		select {
+		case event := <-received:
+			if event.ID != want {
+				t.Fatalf("listener got event %d, want %d", event.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("listener never got event %d", want)
+		}
+	}
+}
+
+func TestListenersMayPublish(t *testing.T) {
+	hub := NewHub()
+
+	received := make(chan Event, 10)
+	hub.AddListener(func(slug string, event Event) {
+		received <- event
+		if event.Type == TypeIncidentUpdate {
+			hub.Publish(slug, Event{Type: TypeStatusChange})
+		}
+	})
+
+	hub.Publish("acme", Event{Type: TypeIncidentUpdate})
+
+	for _, want := range []string{TypeIncidentUpdate, TypeStatusChange} {
+		select {
+		case event := <-received:
+			if event.Type != want {
+				t.Fatalf("listener got %s, want %s", event.Type, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("listener never got %s", want)
+		}
+	}
+}
+
+func TestSlowSubscribersAreDroppedAndResume(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe("acme")
+
+	for i := 0; i < subscriberBuffer+5; i++ {
+		hub.Publish("acme", Event{Type: TypeStatusChange})
+	}
+
+	// The buffered events are still delivered, then the stream ends
+	var lastID uint64
+	for event := range sub.Events {
+		lastID = event.ID
+	}
+	if lastID != subscriberBuffer {
+		t.Fatalf("subscriber got events up to %d, want %d", lastID, subscriberBuffer)
+	}
+
+	hub.Unsubscribe(sub) // Already gone, must not close the channel twice
+
+	_, missed, complete := hub.Resume("acme", hub.EventID(lastID))
+	if !complete || len(missed) != 5 || missed[0].ID != lastID+1 {
+		t.Fatalf("resume after %d: complete %v, %d missed events", lastID, complete, len(missed))
+	}
+}
+
+func TestListenersPublishingBurstsDoNotBlock(t *testing.T) {
+	hub := NewHub()
+
+	const burst = 5000
+	done := make(chan struct{})
+	hub.AddListener(func(slug string, event Event) {
+		if event.Type != TypeIncidentUpdate {
+			return
+		}
+		// Far more events than the listener can handle while it is busy here
+		for i := 0; i < burst; i++ {
+			hub.Publish(slug, Event{Type: TypeStatusChange})
+		}
+		close(done)
+	})
+
+	hub.Publish("acme", Event{Type: TypeIncidentUpdate})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a listener publishing a burst of events blocked on its own queue")
+	}
+}
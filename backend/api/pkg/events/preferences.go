@@ -0,0 +1,114 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Event kinds subscribers choose from
+const (
+	KindIncidents     = "incidents"
+	KindMaintenance   = "maintenance"
+	KindStatusChanges = "status_changes"
+	KindSLOAlerts     = "slo_alerts"
+)
+
+// Kinds lists the event kinds subscribers choose from
+var Kinds = []string{KindIncidents, KindMaintenance, KindStatusChanges, KindSLOAlerts}
+
+// Kind returns the kind of the event
+func (e Event) Kind() string {
+	switch e.Type {
+	case TypeIncidentUpdate:
+		return KindIncidents
+	case TypeMaintenanceUpdate:
+		return KindMaintenance
+	case TypeStatusChange:
+		return KindStatusChanges
+	case TypeSLOAlert:
+		return KindSLOAlerts
+	}
+	return ""
+}
+
+// ServiceIDs returns the IDs of the services the event is about. Incidents
+// must be published with their affected services loaded.
+func (e Event) ServiceIDs() []string {
+	switch {
+	case e.Incident != nil:
+		ids := make([]string, 0, len(e.Incident.Services))
+		for _, link := range e.Incident.Services {
+			ids = append(ids, link.ServiceID)
+		}
+		return ids
+	case e.Maintenance != nil:
+		return []string{e.Maintenance.ServiceID}
+	case e.SLOAlert != nil:
+		return []string{e.SLOAlert.ServiceID}
+	case e.Service != "":
+		return []string{e.Service}
+	}
+	return nil
+}
+
+// Preferences narrow down the events a subscriber hears about. An empty list
+// does not filter.
+type Preferences struct {
+	ServiceIDs []string
+	Kinds      []string
+}
+
+// ParsePreferences reads preferences stored as comma-separated lists
+func ParsePreferences(serviceIDs string, kinds string) Preferences {
+	return Preferences{
+		ServiceIDs: splitList(serviceIDs),
+		Kinds:      splitList(kinds),
+	}
+}
+
+// Matches reports whether the event is of a chosen kind and about at least
+// one of the chosen services
+func (p Preferences) Matches(event Event) bool {
+	if len(p.Kinds) > 0 && !contains(p.Kinds, event.Kind()) {
+		return false
+	}
+
+	if len(p.ServiceIDs) == 0 {
+		return true
+	}
+	for _, serviceID := range event.ServiceIDs() {
+		if contains(p.ServiceIDs, serviceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateKinds returns an error naming the first unknown kind
+func ValidateKinds(kinds []string) error {
+	for _, kind := range kinds {
+		if !contains(Kinds, kind) {
+			return fmt.Errorf("Unknown event kind %s", kind)
+		}
+	}
+	return nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
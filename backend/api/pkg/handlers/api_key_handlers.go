@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse describes an API key without its hash
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(key models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     strings.Split(key.Scopes, ","),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedBy:  key.CreatedBy,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// CreateAPIKey handles POST request to create an API key for the organization.
+// The key itself is only returned in this response.
+func CreateAPIKey(c *fiber.Ctx) error {
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unknown scope " + scope,
+			})
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Expiry must be in the future",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate API key",
+		})
+	}
+
+	key := models.APIKey{
+		Name:           req.Name,
+		Prefix:         prefix,
+		KeyHash:        hash,
+		Scopes:         strings.Join(req.Scopes, ","),
+		ExpiresAt:      req.ExpiresAt,
+		CreatedBy:      auth.UserID(c),
+		OrganizationID: org.ID,
+	}
+
+	if err := database.Create(&key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"api_key": newAPIKeyResponse(key),
+		"key":     secret,
+	})
+}
+
+// ListAPIKeys handles GET request to fetch the organization's API keys,
+// revoked ones included
+func ListAPIKeys(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var keys []models.APIKey
+	if err := database.Where("organization_id = ?", org.ID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch API keys",
+		})
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, newAPIKeyResponse(key))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// RevokeAPIKey handles DELETE request to revoke an API key of the organization.
+// Revoked keys stay listed so their use can still be traced.
+func RevokeAPIKey(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var key models.APIKey
+	if err := database.Where("id = ? AND organization_id = ?", c.Params("id"), org.ID).First(&key).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API key not found or does not belong to the organization",
+		})
+	}
+
+	if key.RevokedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "API key is already revoked",
+		})
+	}
+
+	now := time.Now()
+	if err := database.Model(&key).Update("revoked_at", now).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke API key",
+		})
+	}
+	key.RevokedAt = &now
+
+	return c.Status(fiber.StatusOK).JSON(newAPIKeyResponse(key))
+}
+
+func validScope(scope string) bool {
+	for _, known := range auth.Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
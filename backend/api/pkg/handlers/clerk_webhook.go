@@ -1,26 +1,89 @@
 package handlers
 
 import (
-	"fmt"
-	"os"
-	"strings"
+	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
 	"github.com/apsinghdev/PopenStatus/api/pkg/db"
 	"github.com/apsinghdev/PopenStatus/api/pkg/models"
 	"github.com/gofiber/fiber/v2"
 	svix "github.com/svix/svix-webhooks/go"
+	"gorm.io/gorm"
 )
 
 type ClerkWebhookEvent struct {
-	Data struct {
-		ID          string `json:"id"`
-		Name        string `json:"name"`
-		Slug        string `json:"slug"`
-		Description string `json:"description"`
-	} `json:"data"`
-	Type string `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"` // Milliseconds since the epoch
+}
+
+// subject returns the Clerk IDs of the object the event is about and of its organization
+func (e ClerkWebhookEvent) subject() (objectID string, orgID string) {
+	var data struct {
+		ID             string `json:"id"`
+		OrganizationID string `json:"organization_id"`
+		Organization   struct {
+			ID string `json:"id"`
+		} `json:"organization"`
+	}
+	json.Unmarshal(e.Data, &data)
+
+	switch {
+	case strings.HasPrefix(e.Type, "organization."):
+		return data.ID, data.ID
+	case data.Organization.ID != "":
+		return data.ID, data.Organization.ID
+	default:
+		return data.ID, data.OrganizationID
+	}
+}
+
+// occurredAt returns when Clerk emitted the event, falling back to when Svix
+// first sent it
+func (e ClerkWebhookEvent) occurredAt(c *fiber.Ctx) time.Time {
+	if e.Timestamp > 0 {
+		return time.UnixMilli(e.Timestamp)
+	}
+	if seconds, err := strconv.ParseInt(c.Get("svix-timestamp"), 10, 64); err == nil {
+		return time.Unix(seconds, 0)
+	}
+	return time.Now()
+}
+
+type clerkOrganizationData struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+}
+
+type clerkMembershipData struct {
+	ID           string                `json:"id"`
+	Role         string                `json:"role"`
+	Organization clerkOrganizationData `json:"organization"`
+	PublicUser   struct {
+		UserID string `json:"user_id"`
+	} `json:"public_user_data"`
+}
+
+type clerkInvitationData struct {
+	ID             string `json:"id"`
+	EmailAddress   string `json:"email_address"`
+	OrganizationID string `json:"organization_id"`
+	Role           string `json:"role"`
+	Status         string `json:"status"`
+}
+
+type clerkUserData struct {
+	ID string `json:"id"`
 }
 
 func verifyWebhookSignature(c *fiber.Ctx) error {
@@ -29,13 +92,11 @@ func verifyWebhookSignature(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "CLERK_WEBHOOK_SIGNING_SECRET is not set")
 	}
 
-	fmt.Println("webhookSecret", webhookSecret)
-
 	wh, err := svix.NewWebhook(webhookSecret)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to initialize Clerk webhook verifier")
 	}
-	fmt.Println("webhook", wh)
+
 	// Convert Fiber headers to net/http headers
 	headers := http.Header{}
 	c.Request().Header.VisitAll(func(key, value []byte) {
@@ -44,16 +105,14 @@ func verifyWebhookSignature(c *fiber.Ctx) error {
 
 	// Validate the signature
 	if err := wh.Verify(c.Body(), headers); err != nil {
-		fmt.Println("😍 invalid webhook signature")
+		log.Printf("clerk webhook: rejected delivery %s: %v", c.Get("svix-id"), err)
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid Clerk webhook signature")
 	}
 
 	return nil
 }
 
-
 func HandleClerkWebhook(c *fiber.Ctx) error {
-	fmt.Println("🔥 HandleClerkWebhook")
 	// Step 1: Signature verification
 	if err := verifyWebhookSignature(c); err != nil {
 		return err
@@ -67,57 +126,348 @@ func HandleClerkWebhook(c *fiber.Ctx) error {
 		})
 	}
 
-	// Step 3: Only handle organization events
-	if !strings.HasPrefix(event.Type, "organization.") {
+	db := db.GetDB()
+
+	// Step 3: Svix retries deliveries, each one is only handled once
+	record, duplicate, err := recordWebhookEvent(db, c, event)
+	if errors.Is(err, errWebhookInProgress) {
+		// Svix retries later, when the delivery is done or timed out
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Webhook is being processed",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record webhook event",
+		})
+	}
+	if duplicate {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": "Ignored non-organization event",
+			"message": "Webhook already processed",
 		})
 	}
 
-	db := db.GetDB()
+	// Step 4: Skip events older than one already applied to the same object
+	stale, err := isStaleWebhookEvent(db, record)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to order webhook event",
+		})
+	}
+	if stale {
+		finishWebhookEvent(db, &record, models.WebhookOutcomeStale, nil)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Ignored stale event",
+		})
+	}
 
-	switch event.Type {
-	case "organization.created":
-		org := models.Organization{
-			ID: uuid.NewString(),
-			ClerkOrgID: event.Data.ID,
-			Name:       event.Data.Name,
-			Slug:       event.Data.Slug,
-		}
+	// Step 5: Dispatch on the kind of object the event is about
+	switch {
+	case strings.HasPrefix(event.Type, "organization."):
+		err = handleOrganizationEvent(db, event)
+	case strings.HasPrefix(event.Type, "organizationMembership."):
+		err = handleMembershipEvent(db, event)
+	case strings.HasPrefix(event.Type, "organizationInvitation."):
+		err = handleInvitationEvent(db, event)
+	case event.Type == "user.deleted":
+		err = handleUserDeleted(db, event)
+	default:
+		finishWebhookEvent(db, &record, models.WebhookOutcomeIgnored, nil)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Ignored unsupported event",
+		})
+	}
+
+	if err != nil {
+		finishWebhookEvent(db, &record, models.WebhookOutcomeFailed, err)
+		return c.Status(webhookErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	finishWebhookEvent(db, &record, models.WebhookOutcomeProcessed, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Webhook processed successfully",
+	})
+}
+
+// webhookProcessingTimeout is how long a delivery may be handled before a
+// retry takes it over, as the request that handled it likely died
+const webhookProcessingTimeout = 5 * time.Minute
+
+// errWebhookInProgress reports a delivery another request is handling
+var errWebhookInProgress = errors.New("webhook event is being processed")
 
-		if err := db.Create(&org).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to create organization",
-			})
+// recordWebhookEvent logs a delivery before it is handled. It reports a
+// duplicate for deliveries that were already handled, and
+// errWebhookInProgress for ones being handled right now. Failed deliveries,
+// and ones whose handling timed out, are handled again.
+func recordWebhookEvent(db *gorm.DB, c *fiber.Ctx, event ClerkWebhookEvent) (models.WebhookEvent, bool, error) {
+	svixID := c.Get("svix-id")
+
+	var record models.WebhookEvent
+	err := db.Where("svix_id = ?", svixID).First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return record, false, err
+	}
+
+	if err == nil {
+		return retryWebhookEvent(db, record)
+	}
+
+	objectID, orgID := event.subject()
+	record = models.WebhookEvent{
+		SvixID:         svixID,
+		Type:           event.Type,
+		ObjectID:       objectID,
+		OrganizationID: orgID,
+		OccurredAt:     event.occurredAt(c),
+		Outcome:        models.WebhookOutcomeProcessing,
+		Payload:        string(c.Body()),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		// A concurrent delivery of the same event won the unique svix ID
+		var existing models.WebhookEvent
+		if db.Where("svix_id = ?", svixID).First(&existing).Error == nil {
+			return retryWebhookEvent(db, existing)
 		}
+		return record, false, err
+	}
+
+	return record, false, nil
+}
+
+// retryWebhookEvent takes over a delivery that was recorded before, if it
+// failed or its handling timed out. Concurrent retries race on the update,
+// only one of them gets to handle the delivery.
+func retryWebhookEvent(db *gorm.DB, record models.WebhookEvent) (models.WebhookEvent, bool, error) {
+	result := db.Model(&record).
+		Where("outcome = ? OR (outcome = ? AND updated_at < ?)",
+			models.WebhookOutcomeFailed, models.WebhookOutcomeProcessing, time.Now().Add(-webhookProcessingTimeout)).
+		Updates(map[string]interface{}{"outcome": models.WebhookOutcomeProcessing, "error": ""})
+	if result.Error != nil {
+		return record, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, false, db.First(&record, record.ID).Error
+	}
+
+	if err := db.First(&record, record.ID).Error; err != nil {
+		return record, false, err
+	}
+	if record.Outcome == models.WebhookOutcomeProcessing {
+		return record, false, errWebhookInProgress
+	}
+	return record, true, nil
+}
+
+// isStaleWebhookEvent reports whether a newer event about the same object
+// was already applied, Svix does not guarantee delivery order
+func isStaleWebhookEvent(db *gorm.DB, record models.WebhookEvent) (bool, error) {
+	if record.ObjectID == "" {
+		return false, nil
+	}
 
-	case "organization.updated":
+	var newer int64
+	err := db.Model(&models.WebhookEvent{}).
+		Where("object_id = ? AND outcome = ? AND occurred_at > ? AND id <> ?",
+			record.ObjectID, models.WebhookOutcomeProcessed, record.OccurredAt, record.ID).
+		Count(&newer).Error
+	return newer > 0, err
+}
+
+func finishWebhookEvent(db *gorm.DB, record *models.WebhookEvent, outcome string, cause error) {
+	now := time.Now()
+	record.Outcome = outcome
+	record.ProcessedAt = &now
+	if cause != nil {
+		record.Error = cause.Error()
+	}
+
+	if err := db.Save(record).Error; err != nil {
+		log.Printf("clerk webhook: failed to record outcome of %s: %v", record.SvixID, err)
+	}
+}
+
+// webhookError is a failure to process a webhook along with the status to answer with
+type webhookError struct {
+	status  int
+	message string
+}
+
+func (e *webhookError) Error() string {
+	return e.message
+}
+
+func webhookErrorStatus(err error) int {
+	var webhookErr *webhookError
+	if errors.As(err, &webhookErr) {
+		return webhookErr.status
+	}
+	return fiber.StatusInternalServerError
+}
+
+func handleOrganizationEvent(db *gorm.DB, event ClerkWebhookEvent) error {
+	var data clerkOrganizationData
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return &webhookError{fiber.StatusBadRequest, "Invalid organization payload"}
+	}
+
+	switch event.Type {
+	case "organization.created", "organization.updated":
+		// Upsert so retried and reordered deliveries converge on the same row
 		var org models.Organization
-		if err := db.Where("clerk_org_id = ?", event.Data.ID).First(&org).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Organization not found",
-			})
+		err := db.Where("clerk_org_id = ?", data.ID).First(&org).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return &webhookError{fiber.StatusInternalServerError, "Failed to fetch organization"}
+		}
+		if org.ID == "" {
+			org.ID = uuid.NewString()
+			org.ClerkOrgID = data.ID
 		}
 
-		org.Name = event.Data.Name
-		org.Slug = event.Data.Slug
+		org.Name = data.Name
+		org.Slug = data.Slug
 
 		if err := db.Save(&org).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update organization",
-			})
+			return &webhookError{fiber.StatusInternalServerError, "Failed to save organization"}
 		}
 
 	case "organization.deleted":
-		if err := db.Where("clerk_org_id = ?", event.Data.ID).Delete(&models.Organization{}).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to delete organization",
-			})
+		if err := db.Where("clerk_org_id = ?", data.ID).Delete(&models.Organization{}).Error; err != nil {
+			return &webhookError{fiber.StatusInternalServerError, "Failed to delete organization"}
 		}
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Webhook processed successfully",
-	})
+	return nil
 }
 
+// handleMembershipEvent keeps OrganizationMember rows and their roles in sync
+// with the organization memberships in Clerk
+func handleMembershipEvent(db *gorm.DB, event ClerkWebhookEvent) error {
+	var data clerkMembershipData
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.PublicUser.UserID == "" {
+		return &webhookError{fiber.StatusBadRequest, "Invalid membership payload"}
+	}
+
+	var org models.Organization
+	if err := db.Where("clerk_org_id = ?", data.Organization.ID).First(&org).Error; err != nil {
+		return &webhookError{fiber.StatusNotFound, "Organization not found"}
+	}
+
+	var member models.OrganizationMember
+	err := db.Where("clerk_user_id = ? AND organization_id = ?", data.PublicUser.UserID, org.ID).First(&member).Error
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &webhookError{fiber.StatusInternalServerError, "Failed to fetch membership"}
+	}
+
+	switch event.Type {
+	case "organizationMembership.created", "organizationMembership.updated":
+		member.ClerkUserID = data.PublicUser.UserID
+		member.OrganizationID = org.ID
+		member.Role = auth.NormalizeRole(data.Role)
+
+		if err := db.Save(&member).Error; err != nil {
+			return &webhookError{fiber.StatusInternalServerError, "Failed to save membership"}
+		}
+
+	case "organizationMembership.deleted":
+		if !found {
+			return nil
+		}
+		if err := db.Delete(&member).Error; err != nil {
+			return &webhookError{fiber.StatusInternalServerError, "Failed to delete membership"}
+		}
+	}
+
+	return nil
+}
+
+// handleInvitationEvent records organization invitations so pending ones can
+// be listed through the API
+func handleInvitationEvent(db *gorm.DB, event ClerkWebhookEvent) error {
+	var data clerkInvitationData
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.ID == "" {
+		return &webhookError{fiber.StatusBadRequest, "Invalid invitation payload"}
+	}
+
+	var org models.Organization
+	if err := db.Where("clerk_org_id = ?", data.OrganizationID).First(&org).Error; err != nil {
+		return &webhookError{fiber.StatusNotFound, "Organization not found"}
+	}
+
+	var invitation models.OrganizationInvitation
+	if err := db.Where("clerk_invitation_id = ?", data.ID).First(&invitation).Error; err != nil &&
+		!errors.Is(err, gorm.ErrRecordNotFound) {
+		return &webhookError{fiber.StatusInternalServerError, "Failed to fetch invitation"}
+	}
+
+	// The event type is the most reliable hint of the invitation's state
+	status := data.Status
+	switch event.Type {
+	case "organizationInvitation.accepted":
+		status = "accepted"
+	case "organizationInvitation.revoked":
+		status = "revoked"
+	}
+	if status == "" {
+		status = "pending"
+	}
+
+	invitation.ClerkInvitationID = data.ID
+	invitation.EmailAddress = data.EmailAddress
+	invitation.Role = auth.NormalizeRole(data.Role)
+	invitation.Status = status
+	invitation.OrganizationID = org.ID
+
+	if err := db.Save(&invitation).Error; err != nil {
+		return &webhookError{fiber.StatusInternalServerError, "Failed to save invitation"}
+	}
+
+	return nil
+}
+
+// handleUserDeleted removes every membership of a deleted Clerk user
+func handleUserDeleted(db *gorm.DB, event ClerkWebhookEvent) error {
+	var data clerkUserData
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.ID == "" {
+		return &webhookError{fiber.StatusBadRequest, "Invalid user payload"}
+	}
+
+	if err := db.Where("clerk_user_id = ?", data.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+		return &webhookError{fiber.StatusInternalServerError, "Failed to delete memberships"}
+	}
+
+	return nil
+}
+
+// ListWebhookEvents handles GET request to fetch the Clerk webhook events
+// received for the organization, newest first, optionally filtered by type
+// and outcome
+func ListWebhookEvents(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	query := db.GetDB().Where("organization_id = ?", auth.OrganizationID(c))
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if outcome := c.Query("outcome"); outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+
+	var events []models.WebhookEvent
+	if err := query.Order("occurred_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch webhook events",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(events)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRecordWebhookEventRetries(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := database.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := database.AutoMigrate(&models.WebhookEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	// record records a delivery of the event as the webhook handler would
+	record := func(svixID string) (models.WebhookEvent, bool, error) {
+		var (
+			event     models.WebhookEvent
+			duplicate bool
+			err       error
+		)
+		app := fiber.New()
+		app.Post("/", func(c *fiber.Ctx) error {
+			event, duplicate, err = recordWebhookEvent(database, c, ClerkWebhookEvent{Type: "user.deleted"})
+			return nil
+		})
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("svix-id", svixID)
+		if _, testErr := app.Test(req); testErr != nil {
+			t.Fatalf("request: %v", testErr)
+		}
+		return event, duplicate, err
+	}
+
+	// outcome stores a delivery of the event with the outcome, last touched at updatedAt
+	outcome := func(svixID, outcome string, updatedAt time.Time) {
+		if _, _, err := record(svixID); err != nil {
+			t.Fatalf("record %s: %v", svixID, err)
+		}
+		database.Model(&models.WebhookEvent{}).Where("svix_id = ?", svixID).
+			UpdateColumns(map[string]interface{}{"outcome": outcome, "updated_at": updatedAt})
+	}
+
+	outcome("msg_processed", models.WebhookOutcomeProcessed, time.Now())
+	outcome("msg_failed", models.WebhookOutcomeFailed, time.Now())
+	outcome("msg_processing", models.WebhookOutcomeProcessing, time.Now())
+	outcome("msg_timed_out", models.WebhookOutcomeProcessing, time.Now().Add(-webhookProcessingTimeout-time.Minute))
+
+	tests := []struct {
+		svixID        string
+		wantDuplicate bool
+		wantErr       error
+	}{
+		{"msg_new", false, nil},
+		{"msg_processed", true, nil},
+		{"msg_failed", false, nil},
+		{"msg_processing", false, errWebhookInProgress},
+		{"msg_timed_out", false, nil},
+	}
+
+	for _, tt := range tests {
+		event, duplicate, err := record(tt.svixID)
+		if duplicate != tt.wantDuplicate || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: duplicate %v, err %v, want %v, %v", tt.svixID, duplicate, err, tt.wantDuplicate, tt.wantErr)
+		}
+		if !duplicate && err == nil && event.Outcome != models.WebhookOutcomeProcessing {
+			t.Errorf("%s: handled with outcome %s", tt.svixID, event.Outcome)
+		}
+	}
+
+	// Once taken over, a retry is in progress for the other deliveries
+	if _, _, err := record("msg_timed_out"); !errors.Is(err, errWebhookInProgress) {
+		t.Errorf("second retry of a timed out delivery: err %v", err)
+	}
+}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"fmt"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
 	"github.com/apsinghdev/PopenStatus/api/pkg/db"
 	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -22,3 +27,91 @@ func ListOrganizations(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(organizations)
 }
+
+type UpdateOrganizationSettingsRequest struct {
+	OrganizationID string `json:"organization_id" validate:"required"`
+	StatusMode     string `json:"status_mode" validate:"required,oneof=manual derived"`
+}
+
+// UpdateOrganizationSettings handles PUT request to change how the
+// organization's service statuses are decided
+func UpdateOrganizationSettings(c *fiber.Ctx) error {
+	var req UpdateOrganizationSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// The organization always comes from the session
+	req.OrganizationID = auth.OrganizationID(c)
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", req.OrganizationID).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	if err := database.Model(&org).Update("status_mode", req.StatusMode).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update organization settings",
+		})
+	}
+
+	// Apply the derived statuses right away instead of on the next change
+	var serviceList []models.Service
+	if err := database.Where("organization_id = ?", org.ID).Find(&serviceList).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch services",
+		})
+	}
+	cause := services.StatusCause{
+		Source:      models.StatusSourceManual,
+		ActorUserID: services.RequestActor(c),
+		Reason:      "Status mode changed to " + req.StatusMode,
+	}
+	for _, service := range serviceList {
+		if err := services.RefreshServiceStatus(database, fmt.Sprint(service.ID), cause); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to refresh service statuses",
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(org)
+}
+
+// ListInvitations handles GET request to fetch the organization's invitations,
+// the pending ones unless another status is asked for
+func ListInvitations(c *fiber.Ctx) error {
+	status := c.Query("status", "pending")
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var invitations []models.OrganizationInvitation
+	if err := database.Where("organization_id = ? AND status = ?", org.ID, status).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch invitations",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(invitations)
+}
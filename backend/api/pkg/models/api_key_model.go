@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API key scopes, each allowing changes to one kind of resource
+const (
+	ScopeServicesWrite     = "services:write"
+	ScopeIncidentsWrite    = "incidents:write"
+	ScopeMaintenancesWrite = "maintenances:write"
+	ScopeMonitorsWrite     = "monitors:write"
+	ScopeSLOsWrite         = "slos:write"
+)
+
+type APIKey struct {
+	gorm.Model
+	Name           string `gorm:"not null"`             // e.g., "GitHub Actions"
+	Prefix         string `gorm:"not null;uniqueIndex"` // Public part of the key that identifies it
+	KeyHash        string `gorm:"not null"`             // SHA-256 of the full key, the key itself is never stored
+	Scopes         string `gorm:"not null"`             // Comma-separated, e.g. "services:write,incidents:write"
+	ExpiresAt      *time.Time
+	LastUsedAt     *time.Time
+	RevokedAt      *time.Time
+	CreatedBy      string `gorm:"not null"` // Clerk user ID
+	OrganizationID string `gorm:"not null;index"`
+	Organization   Organization
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Chat providers an organization can post to
+const (
+	ChatProviderSlack   = "slack"
+	ChatProviderDiscord = "discord"
+	ChatProviderTeams   = "teams"
+)
+
+// ChatIntegration is a chat channel an organization's incidents and status
+// changes are posted to, through the channel's incoming webhook
+type ChatIntegration struct {
+	gorm.Model
+	Provider       string `gorm:"not null"` // Enum: slack/discord/teams
+	Name           string // e.g., "#incidents"
+	WebhookURL     string `gorm:"not null" json:"-"` // Anyone holding it can post to the channel
+	Enabled        bool   `gorm:"not null"`
+	OrganizationID string `gorm:"not null;index"`
+	Organization   Organization
+
+	// What the channel is notified about, comma-separated
+	ServiceIDs string // Empty for every service
+	EventKinds string `gorm:"not null;default:'incidents,status_changes'"` // incidents/maintenance/status_changes/slo_alerts
+
+	// Outcome of the latest post
+	LastPostedAt *time.Time
+	LastError    string
+}
+
+// ChatPost records that something was posted to a chat integration. Events
+// are published again whenever their incident or service is saved, so posts
+// are keyed on what changed to only post it once.
+type ChatPost struct {
+	gorm.Model
+	IntegrationID uint   `gorm:"not null;uniqueIndex:idx_chat_post_key"`
+	DedupKey      string `gorm:"not null;uniqueIndex:idx_chat_post_key"` // e.g., "incident_update:12"
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Monitor struct {
+	gorm.Model
+	Name           string `gorm:"not null"`                // e.g., "Public API health"
+	Type           string `gorm:"not null;default:'http'"` // Enum: http/tcp/dns/tls/heartbeat
+	Enabled        bool   `gorm:"not null"`
+	ServiceID      string `gorm:"not null;index"` // Foreign key to Service
+	Service        Service
+	OrganizationID string `gorm:"not null"`
+	Organization   Organization
+
+	// HTTP check settings
+	URL                 string
+	Method              string `gorm:"not null;default:'GET'"`
+	ExpectedStatusCodes string `gorm:"not null;default:'200-299'"` // e.g., "200,204" or "200-299"
+	Keyword             string // Text the response body must contain, if any
+
+	// TCP/TLS target, or the name to resolve for DNS checks
+	Host string
+	Port int
+
+	// DNS check settings
+	RecordType     string // Enum: A/CNAME
+	ExpectedValues string // Comma-separated records that must all be returned
+	Resolver       string // e.g., "1.1.1.1:53", empty uses the system resolver
+
+	// TLS check settings
+	ExpiryWarningDays int `gorm:"not null"` // Days before certificate expiry the service is degraded, 0 turns the warning off
+
+	// Heartbeat settings: the job pings the URL of PingToken at least every
+	// IntervalSeconds, plus GraceSeconds of slack. The token is only shown
+	// once, when the monitor is created.
+	PingToken     string `gorm:"index" json:"-"`
+	GraceSeconds  int    `gorm:"not null;default:0"`
+	LastPingAt    *time.Time
+	LastStartedAt *time.Time // Set by a start ping until the job reports success or failure
+
+	TimeoutSeconds  int `gorm:"not null;default:10"`
+	IntervalSeconds int `gorm:"not null;default:60"`
+
+	// Consecutive failures before the service is moved to each status (0 disables the level)
+	DegradedThreshold      int `gorm:"not null"`
+	PartialOutageThreshold int `gorm:"not null"`
+	MajorOutageThreshold   int `gorm:"not null"`
+	// Consecutive successes before the service is considered operational again
+	RecoveryThreshold int `gorm:"not null"`
+
+	// Open an incident for the service while the monitor reports a failure
+	OpenIncident bool   `gorm:"not null;default:false"`
+	IncidentID   string // Incident currently opened by this monitor, if any
+
+	// Check state maintained by the scheduler
+	CurrentStatus        string `gorm:"not null;default:'operational'"` // Status this monitor reports for the service
+	ConsecutiveFailures  int    `gorm:"not null;default:0"`
+	ConsecutiveSuccesses int    `gorm:"not null;default:0"`
+	LastCheckedAt        *time.Time
+	LastError            string
+}
+
+type MonitorCheckResult struct {
+	gorm.Model
+	MonitorID  uint `gorm:"not null;index"`
+	Monitor    Monitor
+	Success    bool `gorm:"not null"`
+	Warning    bool `gorm:"not null;default:false"` // Check passed but needs attention, e.g., certificate near expiry
+	StatusCode int  // HTTP status code, if any
+	LatencyMs  int64
+	Error      string
+	CheckedAt  time.Time `gorm:"not null;index"`
+}
@@ -16,6 +16,15 @@ type Organization struct {
 	Name string `gorm:"not null" json:"name"`
 	Slug string `gorm:"uniqueIndex;not null" json:"slug"`
 
+	// How service statuses are decided, see StatusModeManual and StatusModeDerived
+	StatusMode string `gorm:"not null;default:'manual'" json:"status_mode"`
+
+	// Slack workspace whose /status command acts on the organization, empty when none
+	SlackTeamID string `gorm:"index" json:"slack_team_id"`
+	// One-time code that links the workspace it is run from with /status link
+	SlackLinkCode          string     `gorm:"index" json:"-"`
+	SlackLinkCodeExpiresAt *time.Time `json:"-"`
+
 	// Relations
 	Services  []Service            `gorm:"foreignKey:OrganizationID" json:"services,omitempty"`
 	Incidents []Incident           `gorm:"foreignKey:OrganizationID" json:"incidents,omitempty"`
@@ -26,43 +35,91 @@ type Service struct {
 	gorm.Model
 	Name           string `gorm:"not null"` // e.g., "API"
 	Description    string
-	Status         string `gorm:"not null;default:'operational'"` // Enum: operational/degraded/partial_outage/major_outage
+	Status         string `gorm:"not null;default:'operational'"` // Enum: operational/degraded/partial_outage/major_outage/under_maintenance
 	UserID         string `gorm:"not null"`                       // Clerk user ID
 	OrganizationID string `gorm:"not null"`
 	Organization   Organization
+
+	// Worst of the open incidents, monitors and active maintenance, kept up to date in every mode
+	ComputedStatus string `gorm:"not null;default:'operational'"`
+	// Operator override of the computed status in derived mode, empty when none
+	ManualStatus          string
+	ManualStatusExpiresAt *time.Time
 }
 
+// Organization status modes
+const (
+	// StatusModeManual lets operators and monitors set service statuses directly
+	StatusModeManual = "manual"
+	// StatusModeDerived uses the computed status unless a manual override is active
+	StatusModeDerived = "derived"
+)
+
+// Service statuses, ordered from best to worst
+const (
+	ServiceStatusOperational   = "operational"
+	ServiceStatusDegraded      = "degraded"
+	ServiceStatusPartialOutage = "partial_outage"
+	ServiceStatusMajorOutage   = "major_outage"
+)
+
+// ServiceStatusUnderMaintenance is set while a maintenance of the service is in progress
+const ServiceStatusUnderMaintenance = "under_maintenance"
+
 type Incident struct {
 	gorm.Model
 	Title          string `gorm:"not null"` // e.g., "Database Outage"
 	Description    string
-	Status         string `gorm:"not null"` // Enum: investigating/identified/resolved
+	Status         string `gorm:"not null"` // Enum: investigating/identified/monitoring/resolved
 	Severity       string // Optional: critical/high/medium/low
-	ServiceID      string   // Foreign key to Service
-	Service        Service
-	OrganizationID string `gorm:"not null"`
+	IdentifiedAt   *time.Time
+	ResolvedAt     *time.Time // Cleared when the incident is reopened
+	OrganizationID string     `gorm:"not null"`
 	Organization   Organization
-	Updates        []IncidentUpdate `gorm:"foreignKey:IncidentID"`
+	Services       []IncidentService `gorm:"foreignKey:IncidentID"` // Affected services
+	Updates        []IncidentUpdate  `gorm:"foreignKey:IncidentID"`
+}
+
+// Incident statuses, in the order an incident moves through them
+const (
+	IncidentStatusInvestigating = "investigating"
+	IncidentStatusIdentified    = "identified"
+	IncidentStatusMonitoring    = "monitoring"
+	IncidentStatusResolved      = "resolved"
+)
+
+// IncidentService links an incident to a service it affects
+type IncidentService struct {
+	ID         uint    `gorm:"primarykey"`
+	IncidentID uint    `gorm:"not null;uniqueIndex:idx_incident_service"`       // Foreign key to Incident
+	ServiceID  string  `gorm:"not null;uniqueIndex:idx_incident_service;index"` // Foreign key to Service
+	Service    Service `gorm:"-:migration"`                                     // Preload only, the key types differ
+	Impact     string  `gorm:"not null"`                                        // Enum: degraded/partial_outage/major_outage
 }
 
 type IncidentUpdate struct {
 	gorm.Model
-	Message    string `gorm:"not null"` // e.g., "Root cause identified"
-	IncidentID string   // Foreign key to Incident
+	Message    string    `gorm:"not null"` // e.g., "Root cause identified"
+	Status     string    // Incident status as of this update
+	Timestamp  time.Time // When the update was posted, kept when its message is edited
+	IncidentID string    // Foreign key to Incident
 	Incident   Incident
 }
 
 type Maintenance struct {
 	gorm.Model
-	Title          string `gorm:"not null"`
-	Description    string
-	ScheduledStart time.Time
-	ScheduledEnd   time.Time
-	Status         string `gorm:"not null"` // Enum: scheduled/in_progress/completed
-	ServiceID      string   // Foreign key to Service
-	Service        Service
-	OrganizationID string `gorm:"not null"`
-	Organization   Organization
+	Title                 string `gorm:"not null"`
+	Description           string
+	ScheduledStart        time.Time
+	ScheduledEnd          time.Time
+	Status                string `gorm:"not null"` // Enum: scheduled/in_progress/completed/cancelled
+	StartedAt             *time.Time
+	CompletedAt           *time.Time
+	PreviousServiceStatus string // Service status to restore when the maintenance ends
+	ServiceID             string // Foreign key to Service
+	Service               Service
+	OrganizationID        string `gorm:"not null"`
+	Organization          Organization
 }
 
 type OrganizationMember struct {
@@ -70,5 +127,16 @@ type OrganizationMember struct {
 	ClerkUserID    string `gorm:"not null"` // Clerk's external user ID (no local user table needed)
 	OrganizationID string `gorm:"not null"`
 	Organization   Organization
-	Role           string `gorm:"not null"` // Enum: admin/member
+	Role           string `gorm:"not null"` // Enum: owner/admin/responder/viewer
+	SlackUserID    string `gorm:"index"`    // Slack user acting as this member through the /status command
+}
+
+type OrganizationInvitation struct {
+	gorm.Model
+	ClerkInvitationID string `gorm:"uniqueIndex;not null"`
+	EmailAddress      string `gorm:"not null"`
+	Role              string `gorm:"not null"` // Role the invitee gets on accepting
+	Status            string `gorm:"not null"` // Enum: pending/accepted/revoked
+	OrganizationID    string `gorm:"not null;index"`
+	Organization      Organization
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SLO struct {
+	gorm.Model
+	Name             string `gorm:"not null"`       // e.g., "API availability"
+	ServiceID        string `gorm:"not null;index"` // Foreign key to Service
+	Service          Service
+	OrganizationID   string `gorm:"not null"`
+	Organization     Organization
+	TargetPercentage float64 `gorm:"not null"`                  // e.g., 99.9
+	WindowDays       int     `gorm:"not null;default:30"`       // Rolling window the target applies to
+	Source           string  `gorm:"not null;default:'status'"` // Enum: status/checks
+
+	// Alert when the error budget burns faster than BurnRateThreshold times the
+	// sustainable rate, measured over the last BurnRateWindowHours
+	BurnRateThreshold   float64 `gorm:"not null"`
+	BurnRateWindowHours int     `gorm:"not null"`
+}
+
+// Actions published for SLO alerts
+const (
+	SLOAlertTriggered = "triggered"
+	SLOAlertResolved  = "resolved"
+)
+
+type SLOAlert struct {
+	gorm.Model
+	SLOID          uint `gorm:"not null;index"`
+	SLO            SLO
+	ServiceID      string    `gorm:"not null"`
+	OrganizationID string    `gorm:"not null"`
+	BurnRate       float64   `gorm:"not null"` // Burn rate when the alert fired
+	Threshold      float64   `gorm:"not null"`
+	TriggeredAt    time.Time `gorm:"not null"`
+	ResolvedAt     *time.Time
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Subscriber is someone who asked for email notifications from an
+// organization's status page. Only confirmed subscribers are notified.
+type Subscriber struct {
+	gorm.Model
+	Email            string `gorm:"not null;uniqueIndex:idx_subscriber_email"`
+	OrganizationID   string `gorm:"not null;uniqueIndex:idx_subscriber_email"`
+	Organization     Organization
+	ConfirmToken     string     `gorm:"index" json:"-"` // Empty once confirmed
+	ConfirmSentAt    *time.Time // Last confirmation email, resent only after a cooldown
+	ConfirmedAt      *time.Time
+	UnsubscribeToken string `gorm:"not null;uniqueIndex" json:"-"`
+
+	// What the subscriber is notified about, comma-separated
+	ServiceIDs string // Empty for every service
+	EventKinds string `gorm:"not null;default:'incidents,maintenance'"` // incidents/maintenance/status_changes/slo_alerts
+}
+
+// Statuses of a notification to a subscriber
+const (
+	NotificationStatusPending = "pending"
+	NotificationStatusSent    = "sent"
+	NotificationStatusFailed  = "failed"
+)
+
+// SubscriberNotification is an email queued for a subscriber, along with the
+// outcome of its latest attempt
+type SubscriberNotification struct {
+	gorm.Model
+	SubscriberID  uint `gorm:"not null;uniqueIndex:idx_notification_key"`
+	Subscriber    Subscriber
+	DedupKey      string     `gorm:"not null;uniqueIndex:idx_notification_key"` // What the email is about, e.g. "incident_update:12", so it is only sent once
+	Subject       string     `gorm:"not null"`
+	Body          string     `gorm:"type:text;not null"`
+	Status        string     `gorm:"not null"` // Enum: pending/sent/failed
+	Attempts      int        `gorm:"not null"`
+	NextAttemptAt *time.Time `gorm:"index"`
+	SentAt        *time.Time
+	Error         string
+}
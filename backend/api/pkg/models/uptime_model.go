@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// ServiceStatusChange is an append-only record of a status transition. It
+// outlives its service, so postmortems can still reconstruct what happened.
+type ServiceStatusChange struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	ServiceID      string    `gorm:"not null;index"` // Foreign key to Service
+	ServiceName    string    // Name of the service at the time of the change
+	OrganizationID string    `gorm:"not null"`
+	OldStatus      string    // Empty for the status a service was created with
+	NewStatus      string    `gorm:"not null"`
+	ChangedAt      time.Time `gorm:"not null;index"`
+	Source         string    `gorm:"not null;default:'manual'"` // Enum: manual/monitor/incident/maintenance/system
+	ActorUserID    string    // Clerk user ID, empty for automatic changes
+	Reason         string
+}
+
+// Sources of service status changes
+const (
+	StatusSourceManual      = "manual"
+	StatusSourceMonitor     = "monitor"
+	StatusSourceIncident    = "incident"
+	StatusSourceMaintenance = "maintenance"
+	StatusSourceSystem      = "system"
+)
+
+type ServiceUptimeDaily struct {
+	ID             uint      `gorm:"primarykey"`
+	ServiceID      string    `gorm:"not null;uniqueIndex:idx_service_uptime_day"` // Foreign key to Service
+	Day            time.Time `gorm:"type:date;not null;uniqueIndex:idx_service_uptime_day"`
+	OrganizationID string    `gorm:"not null"`
+
+	// Seconds spent in each status during the part of the day the service existed
+	OperationalSeconds   int64 `gorm:"not null;default:0"`
+	DegradedSeconds      int64 `gorm:"not null;default:0"`
+	PartialOutageSeconds int64 `gorm:"not null;default:0"`
+	MajorOutageSeconds   int64 `gorm:"not null;default:0"`
+	MaintenanceSeconds   int64 `gorm:"not null;default:0"` // Not counted towards uptime
+
+	UptimePercentage *float64 // Nil when the service did not exist or was under maintenance all day
+	UpdatedAt        time.Time
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outcomes of processing a received webhook event
+const (
+	WebhookOutcomeProcessing = "processing"
+	WebhookOutcomeProcessed  = "processed"
+	WebhookOutcomeIgnored    = "ignored"
+	WebhookOutcomeStale      = "stale"
+	WebhookOutcomeFailed     = "failed"
+)
+
+// WebhookEvent is a Clerk webhook delivery as received, kept to deduplicate
+// retries, order events and trace how each one was handled
+type WebhookEvent struct {
+	gorm.Model
+	SvixID         string    `gorm:"not null;uniqueIndex"` // Delivery ID, the same across retries
+	Type           string    `gorm:"not null;index"`       // e.g., "organizationMembership.created"
+	ObjectID       string    `gorm:"index"`                // Clerk ID of the object the event is about
+	OrganizationID string    `gorm:"index"`                // Clerk organization ID, empty for user events
+	OccurredAt     time.Time `gorm:"not null"`
+	Outcome        string    `gorm:"not null"` // Enum: processing/processed/ignored/stale/failed
+	Error          string
+	Payload        string `gorm:"type:text"`
+	ProcessedAt    *time.Time
+}
+
+// Statuses of an outgoing webhook delivery
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// WebhookEndpoint is a URL an organization registered to receive its events
+type WebhookEndpoint struct {
+	gorm.Model
+	URL            string `gorm:"not null"`
+	Description    string
+	Secret         string `gorm:"not null" json:"-"` // whsec_ prefixed, signs the deliveries
+	EventTypes     string `gorm:"not null"`          // Comma-separated, e.g. "incident.created,service.status_changed", or "*"
+	ServiceIDs     string // Comma-separated, empty for every service
+	Enabled        bool   `gorm:"not null"`
+	OrganizationID string `gorm:"not null;index"`
+	Organization   Organization
+}
+
+// WebhookDelivery is one event sent to one endpoint, along with the outcome
+// of its latest attempt
+type WebhookDelivery struct {
+	gorm.Model
+	EndpointID     uint            `gorm:"not null;index"`
+	Endpoint       WebhookEndpoint `json:"-"`
+	OrganizationID string          `gorm:"not null;index"`
+	MessageID      string          `gorm:"not null;index"` // Sent as svix-id, the same across attempts
+	EventType      string          `gorm:"not null"`
+	Payload        string          `gorm:"type:text;not null"`
+	Status         string          `gorm:"not null"` // Enum: pending/succeeded/failed
+	Attempts       int             `gorm:"not null"`
+	NextAttemptAt  *time.Time      `gorm:"index"`
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	Error          string
+}
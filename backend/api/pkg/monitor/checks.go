@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+)
+
+// Result is the outcome of a single check run
+type Result struct {
+	Success    bool
+	Warning    bool // Set on successful checks that should still degrade the service
+	StatusCode int
+	Latency    time.Duration
+	Error      string
+}
+
+// Checker runs one type of check against a monitor's target
+type Checker interface {
+	// Validate reports whether the monitor has the settings this checker needs
+	Validate(monitor models.Monitor) error
+	// Check runs the check. The context carries the monitor's timeout.
+	Check(ctx context.Context, monitor models.Monitor) Result
+}
+
+// checkers maps a monitor type to the checker that runs it
+var checkers = map[string]Checker{
+	"http":      HTTPChecker{Client: &http.Client{}},
+	"tcp":       TCPChecker{},
+	"dns":       DNSChecker{},
+	"tls":       TLSChecker{},
+	"heartbeat": HeartbeatChecker{},
+}
+
+// ValidateMonitor checks the monitor's type and type-specific settings
+func ValidateMonitor(monitor models.Monitor) error {
+	checker, ok := checkers[monitor.Type]
+	if !ok {
+		return fmt.Errorf("unsupported monitor type %q", monitor.Type)
+	}
+	return checker.Validate(monitor)
+}
+
+// maxBodySize bounds how much of a response body is searched for the keyword
+const maxBodySize = 1 << 20
+
+// HTTPChecker requests the monitor URL and expects one of its status codes
+// and, when set, the keyword in the response body
+type HTTPChecker struct {
+	Client *http.Client
+}
+
+func (h HTTPChecker) Validate(monitor models.Monitor) error {
+	if monitor.URL == "" {
+		return errors.New("url is required for http monitors")
+	}
+	_, err := ParseStatusCodes(monitor.ExpectedStatusCodes)
+	return err
+}
+
+func (h HTTPChecker) Check(ctx context.Context, monitor models.Monitor) Result {
+	method := monitor.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, monitor.URL, nil)
+	if err != nil {
+		return Result{Error: err.Error()}
+	}
+	req.Header.Set("User-Agent", "PopenStatus-Monitor/1.0")
+
+	start := time.Now()
+	resp, err := h.Client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Latency: latency, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	// Read (a bounded part of) the body, which also lets the connection be reused
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return Result{StatusCode: resp.StatusCode, Latency: latency, Error: err.Error()}
+	}
+
+	result := Result{
+		StatusCode: resp.StatusCode,
+		Latency:    latency,
+	}
+
+	expected, err := ParseStatusCodes(monitor.ExpectedStatusCodes)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !expected.Contains(resp.StatusCode) {
+		result.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+		return result
+	}
+	if monitor.Keyword != "" && !strings.Contains(string(body), monitor.Keyword) {
+		result.Error = fmt.Sprintf("keyword %q not found in the response", monitor.Keyword)
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+// StatusCodes is a set of accepted HTTP status code ranges
+type StatusCodes [][2]int
+
+// ParseStatusCodes parses a list such as "200,204,300-399". An empty list
+// accepts every 2xx code.
+func ParseStatusCodes(spec string) (StatusCodes, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return StatusCodes{{200, 299}}, nil
+	}
+
+	var codes StatusCodes
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		low, high, isRange := strings.Cut(part, "-")
+
+		from, err := parseStatusCode(low)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = parseStatusCode(high); err != nil {
+				return nil, err
+			}
+			if to < from {
+				return nil, fmt.Errorf("invalid status code range %q", part)
+			}
+		}
+
+		codes = append(codes, [2]int{from, to})
+	}
+
+	return codes, nil
+}
+
+func parseStatusCode(s string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q", s)
+	}
+	return code, nil
+}
+
+// Contains reports whether the status code is accepted
+func (s StatusCodes) Contains(code int) bool {
+	for _, r := range s {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
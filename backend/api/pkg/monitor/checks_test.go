@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+)
+
+func TestHTTPCheckerCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "PopenStatus-Monitor/1.0" {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+
+		switch r.URL.Path {
+		case "/healthy":
+			w.Write([]byte(`{"database":"ok"}`))
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/created":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case "/slow":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		monitor   models.Monitor
+		wantOK    bool
+		wantCode  int
+		wantError string
+	}{
+		{"2xx by default", models.Monitor{URL: server.URL + "/healthy"}, true, 200, ""},
+		{"unexpected status code", models.Monitor{URL: server.URL + "/unavailable"}, false, 503, "unexpected status code 503"},
+		{"expected error code", models.Monitor{URL: server.URL + "/unavailable", ExpectedStatusCodes: "500-503"}, true, 503, ""},
+		{"method and code list", models.Monitor{URL: server.URL + "/created", Method: http.MethodPost, ExpectedStatusCodes: "200,201"}, true, 201, ""},
+		{"keyword found", models.Monitor{URL: server.URL + "/healthy", Keyword: `"database":"ok"`}, true, 200, ""},
+		{"keyword missing", models.Monitor{URL: server.URL + "/healthy", Keyword: "redis"}, false, 200, `keyword "redis" not found`},
+		{"unreachable", models.Monitor{URL: "http://127.0.0.1:1/"}, false, 0, "connection refused"},
+	}
+
+	checker := HTTPChecker{Client: &http.Client{}}
+	for _, tt := range tests {
+		result := checker.Check(context.Background(), tt.monitor)
+		if result.Success != tt.wantOK || result.StatusCode != tt.wantCode {
+			t.Errorf("%s: got success %v, status %d, want %v, %d (%s)", tt.name, result.Success, result.StatusCode, tt.wantOK, tt.wantCode, result.Error)
+		}
+		if !strings.Contains(result.Error, tt.wantError) {
+			t.Errorf("%s: error %q, want it to contain %q", tt.name, result.Error, tt.wantError)
+		}
+	}
+
+	// The scheduler bounds every check by the monitor's timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := checker.Check(ctx, models.Monitor{URL: server.URL + "/slow"})
+	if result.Success || !strings.Contains(result.Error, "deadline exceeded") {
+		t.Errorf("slow server: got success %v, error %q, want a timeout", result.Success, result.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow server: check returned after %s", elapsed)
+	}
+}
+
+func TestParseStatusCodes(t *testing.T) {
+	tests := []struct {
+		spec    string
+		accept  []int
+		reject  []int
+		wantErr bool
+	}{
+		{spec: "", accept: []int{200, 204, 299}, reject: []int{199, 301, 500}},
+		{spec: "200,204", accept: []int{200, 204}, reject: []int{201}},
+		{spec: "200, 300-399", accept: []int{200, 302, 399}, reject: []int{400}},
+		{spec: "399-300", wantErr: true},
+		{spec: "200,abc", wantErr: true},
+		{spec: "600", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		codes, err := ParseStatusCodes(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseStatusCodes(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		for _, code := range tt.accept {
+			if !codes.Contains(code) {
+				t.Errorf("ParseStatusCodes(%q) rejects %d", tt.spec, code)
+			}
+		}
+		for _, code := range tt.reject {
+			if codes.Contains(code) {
+				t.Errorf("ParseStatusCodes(%q) accepts %d", tt.spec, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,504 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CreateMonitorRequest struct {
+	Name                   string `json:"name" validate:"required"`
+	Type                   string `json:"type" validate:"required,oneof=http tcp dns tls heartbeat"`
+	ServiceID              string `json:"service_id" validate:"required"`
+	OrganizationID         string `json:"organization_id" validate:"required"`
+	Enabled                *bool  `json:"enabled"`
+	URL                    string `json:"url" validate:"omitempty,url"`
+	Method                 string `json:"method" validate:"omitempty,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	ExpectedStatusCodes    string `json:"expected_status_codes"`
+	Keyword                string `json:"keyword"`
+	Host                   string `json:"host" validate:"omitempty,hostname_rfc1123|ip"`
+	Port                   int    `json:"port" validate:"omitempty,min=1,max=65535"`
+	RecordType             string `json:"record_type" validate:"omitempty,oneof=A CNAME"`
+	ExpectedValues         string `json:"expected_values"`
+	Resolver               string `json:"resolver"`
+	ExpiryWarningDays      *int   `json:"expiry_warning_days" validate:"omitempty,min=0"` // Defaults to 14, 0 turns the warning off
+	GraceSeconds           *int   `json:"grace_seconds" validate:"omitempty,min=0"`
+	OpenIncident           bool   `json:"open_incident"`
+	TimeoutSeconds         int    `json:"timeout_seconds" validate:"omitempty,min=1,max=120"`
+	IntervalSeconds        int    `json:"interval_seconds" validate:"omitempty,min=10"`
+	DegradedThreshold      *int   `json:"degraded_threshold" validate:"omitempty,min=0"`
+	PartialOutageThreshold *int   `json:"partial_outage_threshold" validate:"omitempty,min=0"`
+	MajorOutageThreshold   *int   `json:"major_outage_threshold" validate:"omitempty,min=0"`
+	RecoveryThreshold      *int   `json:"recovery_threshold" validate:"omitempty,min=1"`
+}
+
+type UpdateMonitorRequest struct {
+	Name                   string `json:"name"`
+	Enabled                *bool  `json:"enabled"`
+	URL                    string `json:"url" validate:"omitempty,url"`
+	Method                 string `json:"method" validate:"omitempty,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	ExpectedStatusCodes    string `json:"expected_status_codes"`
+	Keyword                string `json:"keyword"`
+	Host                   string `json:"host" validate:"omitempty,hostname_rfc1123|ip"`
+	Port                   int    `json:"port" validate:"omitempty,min=1,max=65535"`
+	RecordType             string `json:"record_type" validate:"omitempty,oneof=A CNAME"`
+	ExpectedValues         string `json:"expected_values"`
+	Resolver               string `json:"resolver"`
+	ExpiryWarningDays      *int   `json:"expiry_warning_days" validate:"omitempty,min=0"`
+	GraceSeconds           *int   `json:"grace_seconds" validate:"omitempty,min=0"`
+	OpenIncident           *bool  `json:"open_incident"`
+	TimeoutSeconds         int    `json:"timeout_seconds" validate:"omitempty,min=1,max=120"`
+	IntervalSeconds        int    `json:"interval_seconds" validate:"omitempty,min=10"`
+	DegradedThreshold      *int   `json:"degraded_threshold" validate:"omitempty,min=0"`
+	PartialOutageThreshold *int   `json:"partial_outage_threshold" validate:"omitempty,min=0"`
+	MajorOutageThreshold   *int   `json:"major_outage_threshold" validate:"omitempty,min=0"`
+	RecoveryThreshold      *int   `json:"recovery_threshold" validate:"omitempty,min=1"`
+}
+
+// CreateMonitorResponse is a new monitor along with its ping token, which is
+// not returned anywhere else
+type CreateMonitorResponse struct {
+	models.Monitor
+	PingToken string `json:"ping_token,omitempty"`
+}
+
+// Default failure thresholds for new monitors
+const (
+	defaultDegradedThreshold      = 1
+	defaultPartialOutageThreshold = 3
+	defaultMajorOutageThreshold   = 5
+	defaultRecoveryThreshold      = 1
+	defaultExpiryWarningDays      = 14
+	defaultGraceSeconds           = 60
+)
+
+// CreateMonitor creates a monitor for a service of the organization
+func CreateMonitor(c *fiber.Ctx) error {
+	var req CreateMonitorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// The organization always comes from the session
+	req.OrganizationID = auth.OrganizationID(c)
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", req.OrganizationID).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var service models.Service
+	if err := database.Where("id = ? AND organization_id = ?", req.ServiceID, org.ID).First(&service).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Service not found or does not belong to the organization",
+		})
+	}
+
+	monitor := models.Monitor{
+		Name:                   req.Name,
+		Type:                   req.Type,
+		Enabled:                req.Enabled == nil || *req.Enabled,
+		ServiceID:              req.ServiceID,
+		OrganizationID:         org.ID,
+		URL:                    req.URL,
+		Method:                 req.Method,
+		ExpectedStatusCodes:    req.ExpectedStatusCodes,
+		Keyword:                req.Keyword,
+		Host:                   req.Host,
+		Port:                   req.Port,
+		RecordType:             req.RecordType,
+		ExpectedValues:         req.ExpectedValues,
+		Resolver:               req.Resolver,
+		ExpiryWarningDays:      intOrDefault(req.ExpiryWarningDays, defaultExpiryWarningDays),
+		GraceSeconds:           intOrDefault(req.GraceSeconds, defaultGraceSeconds),
+		OpenIncident:           req.OpenIncident,
+		TimeoutSeconds:         req.TimeoutSeconds,
+		IntervalSeconds:        req.IntervalSeconds,
+		DegradedThreshold:      intOrDefault(req.DegradedThreshold, defaultDegradedThreshold),
+		PartialOutageThreshold: intOrDefault(req.PartialOutageThreshold, defaultPartialOutageThreshold),
+		MajorOutageThreshold:   intOrDefault(req.MajorOutageThreshold, defaultMajorOutageThreshold),
+		RecoveryThreshold:      intOrDefault(req.RecoveryThreshold, defaultRecoveryThreshold),
+	}
+
+	if err := ValidateMonitor(monitor); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	// Heartbeat jobs identify themselves with a secret token in the ping URL
+	if monitor.Type == "heartbeat" {
+		token, err := newPingToken()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate ping token",
+			})
+		}
+		monitor.PingToken = token
+	}
+
+	if err := database.Create(&monitor).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create monitor",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateMonitorResponse{
+		Monitor:   monitor,
+		PingToken: monitor.PingToken,
+	})
+}
+
+// ListMonitors lists the monitors of an organization, optionally filtered by service
+func ListMonitors(c *fiber.Ctx) error {
+	clerkOrgID := auth.OrganizationID(c)
+	serviceID := c.Query("service_id")
+
+	if clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Organization ID is required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	query := database.Where("organization_id = ?", org.ID)
+	if serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+
+	var monitors []models.Monitor
+	if err := query.Find(&monitors).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch monitors",
+		})
+	}
+
+	return c.Status(200).JSON(monitors)
+}
+
+// UpdateMonitor updates a monitor's settings
+func UpdateMonitor(c *fiber.Ctx) error {
+	monitorID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if monitorID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Monitor ID and Organization ID are required",
+		})
+	}
+
+	var req UpdateMonitorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var monitor models.Monitor
+	if err := database.Where("id = ? AND organization_id = ?", monitorID, org.ID).First(&monitor).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Monitor not found or does not belong to the organization",
+		})
+	}
+
+	// A failing monitor that is turned off no longer holds its service down
+	wasFailing := monitor.Enabled && monitor.CurrentStatus != models.ServiceStatusOperational
+
+	// Update the monitor fields if they are provided
+	if req.Name != "" {
+		monitor.Name = req.Name
+	}
+	if req.Enabled != nil {
+		monitor.Enabled = *req.Enabled
+	}
+	if req.URL != "" {
+		monitor.URL = req.URL
+	}
+	if req.Method != "" {
+		monitor.Method = req.Method
+	}
+	if req.ExpectedStatusCodes != "" {
+		monitor.ExpectedStatusCodes = req.ExpectedStatusCodes
+	}
+	if req.Keyword != "" {
+		monitor.Keyword = req.Keyword
+	}
+	if req.Host != "" {
+		monitor.Host = req.Host
+	}
+	if req.Port != 0 {
+		monitor.Port = req.Port
+	}
+	if req.RecordType != "" {
+		monitor.RecordType = req.RecordType
+	}
+	if req.ExpectedValues != "" {
+		monitor.ExpectedValues = req.ExpectedValues
+	}
+	if req.Resolver != "" {
+		monitor.Resolver = req.Resolver
+	}
+	monitor.ExpiryWarningDays = intOrDefault(req.ExpiryWarningDays, monitor.ExpiryWarningDays)
+	monitor.GraceSeconds = intOrDefault(req.GraceSeconds, monitor.GraceSeconds)
+	if req.OpenIncident != nil {
+		monitor.OpenIncident = *req.OpenIncident
+	}
+	if req.TimeoutSeconds != 0 {
+		monitor.TimeoutSeconds = req.TimeoutSeconds
+	}
+	if req.IntervalSeconds != 0 {
+		monitor.IntervalSeconds = req.IntervalSeconds
+	}
+	monitor.DegradedThreshold = intOrDefault(req.DegradedThreshold, monitor.DegradedThreshold)
+	monitor.PartialOutageThreshold = intOrDefault(req.PartialOutageThreshold, monitor.PartialOutageThreshold)
+	monitor.MajorOutageThreshold = intOrDefault(req.MajorOutageThreshold, monitor.MajorOutageThreshold)
+	monitor.RecoveryThreshold = intOrDefault(req.RecoveryThreshold, monitor.RecoveryThreshold)
+
+	if err := ValidateMonitor(monitor); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	// Only the settings are written, the check state belongs to the scheduler
+	if err := database.Model(&monitor).Select(settingsColumns).Updates(&monitor).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update monitor",
+		})
+	}
+
+	// A disabled monitor starts over from operational once enabled again
+	if !monitor.Enabled {
+		if err := database.Model(&monitor).Updates(map[string]interface{}{
+			"current_status":        models.ServiceStatusOperational,
+			"consecutive_failures":  0,
+			"consecutive_successes": 0,
+		}).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update monitor",
+			})
+		}
+	}
+
+	// Enabling or disabling the monitor changes the computed service status
+	cause := services.StatusCause{
+		Source:      models.StatusSourceMonitor,
+		ActorUserID: services.RequestActor(c),
+		Reason:      fmt.Sprintf("Monitor %q updated", monitor.Name),
+	}
+	refreshService(database, monitor.ServiceID, wasFailing && !monitor.Enabled, cause)
+
+	if err := database.First(&monitor, monitor.ID).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch monitor",
+		})
+	}
+
+	return c.Status(200).JSON(monitor)
+}
+
+// settingsColumns are the columns of a monitor UpdateMonitor writes
+var settingsColumns = []string{
+	"name", "enabled", "url", "method", "expected_status_codes", "keyword",
+	"host", "port", "record_type", "expected_values", "resolver",
+	"expiry_warning_days", "grace_seconds", "open_incident",
+	"timeout_seconds", "interval_seconds",
+	"degraded_threshold", "partial_outage_threshold", "major_outage_threshold", "recovery_threshold",
+}
+
+// refreshService updates the status of the service after one of its monitors
+// changed. When a failing monitor went away, the status the remaining
+// monitors report is set again, as it would otherwise stay at the failure.
+func refreshService(database *gorm.DB, serviceID string, failingMonitorGone bool, cause services.StatusCause) {
+	var err error
+	if failingMonitorGone {
+		err = syncServiceStatus(database, serviceID, cause)
+	} else {
+		err = services.RefreshServiceStatus(database, serviceID, cause)
+	}
+	if err != nil {
+		log.Printf("monitor: failed to refresh service %s: %v", serviceID, err)
+	}
+}
+
+// DeleteMonitor deletes a monitor and its check results and resolves the
+// incident it opened
+func DeleteMonitor(c *fiber.Ctx) error {
+	monitorID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if monitorID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Monitor ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var monitor models.Monitor
+	if err := database.Where("id = ? AND organization_id = ?", monitorID, org.ID).First(&monitor).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Monitor not found or does not belong to the organization",
+		})
+	}
+
+	// Start a transaction to ensure all deletions are atomic
+	tx := database.Begin()
+
+	// The incident the monitor opened would otherwise never be resolved
+	var publish func()
+	if monitor.IncidentID != "" {
+		var err error
+		publish, err = resolveIncident(tx, &monitor, fmt.Sprintf("Monitor %q was deleted.", monitor.Name))
+		if err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to resolve the monitor's incident",
+			})
+		}
+	}
+
+	if err := tx.Where("monitor_id = ?", monitor.ID).Delete(&models.MonitorCheckResult{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete check results",
+		})
+	}
+
+	if err := tx.Delete(&monitor).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete monitor",
+		})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	if publish != nil {
+		publish()
+	}
+
+	cause := services.StatusCause{
+		Source:      models.StatusSourceMonitor,
+		ActorUserID: services.RequestActor(c),
+		Reason:      fmt.Sprintf("Monitor %q deleted", monitor.Name),
+	}
+	failing := monitor.Enabled && monitor.CurrentStatus != models.ServiceStatusOperational
+	refreshService(database, monitor.ServiceID, failing, cause)
+
+	return c.Status(200).JSON(fiber.Map{
+		"message": "Monitor deleted successfully",
+	})
+}
+
+// ListCheckResults returns the most recent check results of a monitor
+func ListCheckResults(c *fiber.Ctx) error {
+	monitorID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if monitorID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Monitor ID and Organization ID are required",
+		})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var monitor models.Monitor
+	if err := database.Where("id = ? AND organization_id = ?", monitorID, org.ID).First(&monitor).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Monitor not found or does not belong to the organization",
+		})
+	}
+
+	var results []models.MonitorCheckResult
+	if err := database.Where("monitor_id = ?", monitor.ID).
+		Order("checked_at DESC").
+		Limit(limit).
+		Find(&results).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch check results",
+		})
+	}
+
+	return c.Status(200).JSON(results)
+}
+
+func intOrDefault(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// HeartbeatChecker is run by the scheduler only once a heartbeat monitor
+// missed its deadline; successful and failed runs are reported by the job
+// itself through HandleHeartbeatPing
+type HeartbeatChecker struct{}
+
+func (HeartbeatChecker) Validate(monitor models.Monitor) error {
+	if monitor.GraceSeconds < 0 {
+		return errors.New("grace_seconds must not be negative")
+	}
+	return nil
+}
+
+func (HeartbeatChecker) Check(ctx context.Context, monitor models.Monitor) Result {
+	since := monitor.CreatedAt
+	if monitor.LastPingAt != nil {
+		since = *monitor.LastPingAt
+	}
+	return Result{Error: fmt.Sprintf("no ping received since %s", since.Format(time.RFC3339))}
+}
+
+// Due reports whether the monitor is past its deadline and that miss was not
+// recorded yet, or was recorded more than an interval ago
+func (HeartbeatChecker) Due(monitor models.Monitor, now time.Time) bool {
+	deadline := heartbeatDeadline(monitor)
+	if now.Before(deadline) {
+		return false
+	}
+
+	interval := time.Duration(monitor.IntervalSeconds) * time.Second
+	return monitor.LastCheckedAt == nil ||
+		monitor.LastCheckedAt.Before(deadline) ||
+		!now.Before(monitor.LastCheckedAt.Add(interval))
+}
+
+// heartbeatDeadline is when the next ping is expected at the latest
+func heartbeatDeadline(monitor models.Monitor) time.Time {
+	last := monitor.CreatedAt
+	if monitor.LastPingAt != nil {
+		last = *monitor.LastPingAt
+	}
+	period := time.Duration(monitor.IntervalSeconds+monitor.GraceSeconds) * time.Second
+	return last.Add(period)
+}
+
+// newPingToken returns the secret that identifies a heartbeat monitor in its ping URL
+func newPingToken() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// errHeartbeatDisabled is returned for pings to a disabled heartbeat monitor
+var errHeartbeatDisabled = errors.New("heartbeat is disabled")
+
+// HandleHeartbeatPing records a ping from a job. The optional kind parameter
+// is "start" when the job begins, "success" (the default) when it completes
+// and "fail" when it reports a failure.
+func HandleHeartbeatPing(c *fiber.Ctx) error {
+	kind := c.Params("kind", "success")
+
+	if kind != "start" && kind != "success" && kind != "fail" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown ping type",
+		})
+	}
+
+	err := recordPing(db.GetDB(), c.Params("token"), kind, time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Heartbeat not found",
+		})
+	case errors.Is(err, errHeartbeatDisabled):
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Heartbeat is disabled, ping ignored",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record ping",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Ping recorded",
+	})
+}
+
+// recordPing records a ping of the heartbeat monitor with the token. The
+// monitor's row is locked meanwhile, so the scheduler cannot count a missed
+// heartbeat for it at the same time.
+func recordPing(database *gorm.DB, token string, kind string, now time.Time) error {
+	var monitor models.Monitor
+	var changed bool
+	var publish func()
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		monitor, err = lockMonitor(tx.Where("ping_token = ? AND type = ?", token, "heartbeat"))
+		if err != nil {
+			return err
+		}
+
+		if !monitor.Enabled {
+			return errHeartbeatDisabled
+		}
+
+		if kind == "start" {
+			return tx.Model(&monitor).Update("last_started_at", now).Error
+		}
+
+		// The job's duration is only known when it announced its start
+		result := Result{Success: kind == "success"}
+		if monitor.LastStartedAt != nil {
+			result.Latency = now.Sub(*monitor.LastStartedAt)
+		}
+		if !result.Success {
+			result.Error = "job reported failure"
+		}
+
+		if err := tx.Model(&monitor).Updates(map[string]interface{}{
+			"last_ping_at":    now,
+			"last_started_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		monitor.LastPingAt = &now
+		monitor.LastStartedAt = nil
+
+		changed, publish, err = saveResult(tx, &monitor, result, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return finishResult(database, &monitor, changed, publish)
+}
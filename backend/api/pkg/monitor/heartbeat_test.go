@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TestHeartbeatDue(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := created.Add(d)
+		return &t
+	}
+
+	// Pings are expected every minute, with 30 seconds of grace
+	monitor := models.Monitor{IntervalSeconds: 60, GraceSeconds: 30}
+	monitor.CreatedAt = created
+
+	tests := []struct {
+		name        string
+		lastPing    *time.Time
+		lastChecked *time.Time
+		now         time.Duration
+		want        bool
+	}{
+		{"within the grace period of a new monitor", nil, nil, 80 * time.Second, false},
+		{"past the deadline of a new monitor", nil, nil, 90 * time.Second, true},
+		{"within the grace period after a ping", at(time.Minute), nil, 2*time.Minute + 20*time.Second, false},
+		{"past the deadline after a ping", at(time.Minute), nil, 2*time.Minute + 30*time.Second, true},
+		{"miss already recorded", at(time.Minute), at(2*time.Minute + 31*time.Second), 3 * time.Minute, false},
+		{"miss recorded an interval ago", at(time.Minute), at(2*time.Minute + 31*time.Second), 3*time.Minute + 31*time.Second, true},
+		{"check recorded before the latest ping", at(3 * time.Minute), at(2*time.Minute + 31*time.Second), 4 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		m := monitor
+		m.LastPingAt = tt.lastPing
+		m.LastCheckedAt = tt.lastChecked
+		if got := (HeartbeatChecker{}).Due(m, created.Add(tt.now)); got != tt.want {
+			t.Errorf("%s: Due = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// createHeartbeat stores a heartbeat monitor that degrades its service on the
+// first failure and opens an incident on the second
+func createHeartbeat(t *testing.T, database *gorm.DB, token string) models.Monitor {
+	t.Helper()
+
+	return createMonitor(t, database, models.Monitor{
+		Type:                 "heartbeat",
+		PingToken:            token,
+		IntervalSeconds:      60,
+		GraceSeconds:         30,
+		DegradedThreshold:    1,
+		MajorOutageThreshold: 2,
+		RecoveryThreshold:    1,
+		OpenIncident:         true,
+	})
+}
+
+func loadMonitor(t *testing.T, database *gorm.DB, id uint) models.Monitor {
+	t.Helper()
+
+	var monitor models.Monitor
+	if err := database.First(&monitor, id).Error; err != nil {
+		t.Fatalf("load monitor: %v", err)
+	}
+	return monitor
+}
+
+func TestRecordPing(t *testing.T) {
+	database := openTestDB(t)
+	heartbeat := createHeartbeat(t, database, "secret-token")
+	now := time.Now()
+
+	// A start ping only notes when the job began
+	if err := recordPing(database, "secret-token", "start", now); err != nil {
+		t.Fatalf("start ping: %v", err)
+	}
+	stored := loadMonitor(t, database, heartbeat.ID)
+	if stored.LastStartedAt == nil || stored.LastPingAt != nil || stored.LastCheckedAt != nil {
+		t.Fatalf("after a start ping: started %v, pinged %v, checked %v", stored.LastStartedAt, stored.LastPingAt, stored.LastCheckedAt)
+	}
+
+	// A fail ping counts as a failed run that took the time since the start
+	if err := recordPing(database, "secret-token", "fail", now.Add(90*time.Second)); err != nil {
+		t.Fatalf("fail ping: %v", err)
+	}
+	stored = loadMonitor(t, database, heartbeat.ID)
+	if stored.CurrentStatus != models.ServiceStatusDegraded || stored.ConsecutiveFailures != 1 || stored.LastStartedAt != nil {
+		t.Errorf("after a fail ping: %s with %d failures, started %v", stored.CurrentStatus, stored.ConsecutiveFailures, stored.LastStartedAt)
+	}
+	var result models.MonitorCheckResult
+	database.Where("monitor_id = ?", heartbeat.ID).Last(&result)
+	if result.Success || result.LatencyMs != 90000 || result.Error != "job reported failure" {
+		t.Errorf("fail ping stored %+v", result)
+	}
+	if status := serviceStatus(t, database, heartbeat.ServiceID); status != models.ServiceStatusDegraded {
+		t.Errorf("service is %s after a failed run, want degraded", status)
+	}
+
+	// A success ping recovers the service and moves the deadline
+	if err := recordPing(database, "secret-token", "success", now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("success ping: %v", err)
+	}
+	stored = loadMonitor(t, database, heartbeat.ID)
+	if stored.CurrentStatus != models.ServiceStatusOperational || stored.LastPingAt == nil {
+		t.Errorf("after a success ping: %s, pinged %v", stored.CurrentStatus, stored.LastPingAt)
+	}
+	if status := serviceStatus(t, database, heartbeat.ServiceID); status != models.ServiceStatusOperational {
+		t.Errorf("service is %s after a successful run, want operational", status)
+	}
+}
+
+func TestRecordPingLooksUpTheToken(t *testing.T) {
+	database := openTestDB(t)
+	createHeartbeat(t, database, "secret-token")
+
+	disabled := createHeartbeat(t, database, "disabled-token")
+	database.Model(&disabled).Update("enabled", false)
+
+	// Ping tokens only ever match heartbeat monitors
+	createMonitor(t, database, models.Monitor{Type: "http", URL: "https://example.com", PingToken: "http-token"})
+
+	tests := []struct {
+		token string
+		want  error
+	}{
+		{"secret-token", nil},
+		{"unknown-token", gorm.ErrRecordNotFound},
+		{"", gorm.ErrRecordNotFound},
+		{"http-token", gorm.ErrRecordNotFound},
+		{"disabled-token", errHeartbeatDisabled},
+	}
+
+	for _, tt := range tests {
+		if err := recordPing(database, tt.token, "success", time.Now()); !errors.Is(err, tt.want) {
+			t.Errorf("ping with %q: err = %v, want %v", tt.token, err, tt.want)
+		}
+	}
+
+	if stored := loadMonitor(t, database, disabled.ID); stored.LastPingAt != nil {
+		t.Error("a ping to a disabled heartbeat was recorded")
+	}
+}
+
+func TestMissedHeartbeatIsDroppedAfterAPing(t *testing.T) {
+	database := openTestDB(t)
+	heartbeat := createHeartbeat(t, database, "secret-token")
+
+	// makeLate moves the last ping past the deadline, with the last check
+	// recorded at the given time
+	makeLate := func(lastChecked time.Time) models.Monitor {
+		lastPing := time.Now().Add(-4 * time.Minute)
+		database.Model(&heartbeat).Updates(map[string]interface{}{"last_ping_at": lastPing, "last_checked_at": lastChecked})
+		late := loadMonitor(t, database, heartbeat.ID)
+		if !(HeartbeatChecker{}).Due(late, time.Now()) {
+			t.Fatal("the late heartbeat is not due")
+		}
+		return late
+	}
+
+	// The scheduler loaded the monitor past its deadline, then the job pinged
+	// before the missed heartbeat was recorded
+	overdue := makeLate(time.Now().Add(-4 * time.Minute))
+	if err := recordPing(database, "secret-token", "success", time.Now()); err != nil {
+		t.Fatalf("success ping: %v", err)
+	}
+	if err := recordResult(database, &overdue, (HeartbeatChecker{}).Check(context.Background(), overdue)); err != nil {
+		t.Fatalf("recordResult: %v", err)
+	}
+
+	stored := loadMonitor(t, database, heartbeat.ID)
+	if stored.ConsecutiveFailures != 0 || stored.CurrentStatus != models.ServiceStatusOperational {
+		t.Errorf("the stale miss was counted: %s with %d failures", stored.CurrentStatus, stored.ConsecutiveFailures)
+	}
+
+	// Misses of a job that stays late count, and open a single incident
+	for _, lastChecked := range []time.Time{time.Now().Add(-4 * time.Minute), time.Now().Add(-2 * time.Minute)} {
+		late := makeLate(lastChecked)
+		if err := recordResult(database, &late, (HeartbeatChecker{}).Check(context.Background(), late)); err != nil {
+			t.Fatalf("recordResult: %v", err)
+		}
+	}
+
+	stored = loadMonitor(t, database, heartbeat.ID)
+	if stored.CurrentStatus != models.ServiceStatusMajorOutage || stored.ConsecutiveFailures != 2 {
+		t.Errorf("after two misses: %s with %d failures", stored.CurrentStatus, stored.ConsecutiveFailures)
+	}
+	var incidents int64
+	database.Model(&models.Incident{}).Count(&incidents)
+	if incidents != 1 || stored.IncidentID == "" {
+		t.Errorf("%d incidents opened, monitor incident %q", incidents, stored.IncidentID)
+	}
+}
+
+func TestHandleHeartbeatPingRejectsUnknownKinds(t *testing.T) {
+	app := fiber.New()
+	app.Get("/heartbeats/:token/:kind?", HandleHeartbeatPing)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/heartbeats/secret-token/finished", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,90 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"gorm.io/gorm"
+)
+
+// incidentSeverity maps the status a monitor reports to the severity of the incident it opens
+var incidentSeverity = map[string]string{
+	models.ServiceStatusDegraded:      "medium",
+	models.ServiceStatusPartialOutage: "high",
+	models.ServiceStatusMajorOutage:   "critical",
+}
+
+// syncIncident opens an incident when the monitor starts failing and resolves
+// it once the monitor reports the service operational again. The returned
+// function, if any, publishes the change and is meant to be called once the
+// transaction is committed.
+func syncIncident(database *gorm.DB, monitor *models.Monitor) (func(), error) {
+	failing := monitor.CurrentStatus != models.ServiceStatusOperational
+	if failing == (monitor.IncidentID != "") {
+		return nil, nil
+	}
+
+	if failing {
+		var org models.Organization
+		if err := database.Where("id = ?", monitor.OrganizationID).First(&org).Error; err != nil {
+			return nil, err
+		}
+
+		incident := models.Incident{
+			Title:          fmt.Sprintf("%s is failing", monitor.Name),
+			Description:    monitor.LastError,
+			Status:         models.IncidentStatusInvestigating,
+			Severity:       incidentSeverity[monitor.CurrentStatus],
+			OrganizationID: monitor.OrganizationID,
+			Services: []models.IncidentService{
+				{ServiceID: monitor.ServiceID, Impact: monitor.CurrentStatus},
+			},
+		}
+		services.PrepareIncident(&incident, monitor.LastError, time.Now())
+		if err := database.Create(&incident).Error; err != nil {
+			return nil, err
+		}
+
+		monitor.IncidentID = fmt.Sprint(incident.ID)
+		if err := database.Model(monitor).Update("incident_id", monitor.IncidentID).Error; err != nil {
+			return nil, err
+		}
+
+		return func() { events.PublishIncident(org.Slug, "created", incident) }, nil
+	}
+
+	return resolveIncident(database, monitor, fmt.Sprintf("%s is passing again.", monitor.Name))
+}
+
+// resolveIncident resolves the incident the monitor opened, unless an
+// operator already did, and forgets about it
+func resolveIncident(database *gorm.DB, monitor *models.Monitor, message string) (func(), error) {
+	var org models.Organization
+	if err := database.Where("id = ?", monitor.OrganizationID).First(&org).Error; err != nil {
+		return nil, err
+	}
+
+	var incident models.Incident
+	err := database.Preload("Services").Where("id = ?", monitor.IncidentID).First(&incident).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	// The incident may have been deleted by an operator in the meantime
+	var publish func()
+	if err == nil && incident.Status != models.IncidentStatusResolved {
+		if err := database.Transaction(func(tx *gorm.DB) error {
+			_, err := services.TransitionIncident(tx, &incident, models.IncidentStatusResolved, message, time.Now())
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		publish = func() { events.PublishIncident(org.Slug, "updated", incident) }
+	}
+
+	monitor.IncidentID = ""
+	return publish, database.Model(monitor).Update("incident_id", "").Error
+}
@@ -0,0 +1,199 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+)
+
+// TCPChecker succeeds when a TCP connection to host:port can be opened,
+// e.g., for Postgres, Redis or SMTP endpoints
+type TCPChecker struct{}
+
+func (TCPChecker) Validate(monitor models.Monitor) error {
+	return validateHostPort(monitor)
+}
+
+func (TCPChecker) Check(ctx context.Context, monitor models.Monitor) Result {
+	var dialer net.Dialer
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(monitor))
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Latency: latency, Error: err.Error()}
+	}
+	conn.Close()
+
+	return Result{Success: true, Latency: latency}
+}
+
+// DNSChecker resolves the monitor host and expects every configured record
+// to be returned
+type DNSChecker struct{}
+
+func (DNSChecker) Validate(monitor models.Monitor) error {
+	if monitor.Host == "" {
+		return errors.New("host is required for dns monitors")
+	}
+	if monitor.RecordType != "A" && monitor.RecordType != "CNAME" {
+		return errors.New("record_type must be A or CNAME")
+	}
+	return nil
+}
+
+func (DNSChecker) Check(ctx context.Context, monitor models.Monitor) Result {
+	resolver := dnsResolver(monitor.Resolver)
+
+	start := time.Now()
+	records, err := lookup(ctx, resolver, monitor.RecordType, monitor.Host)
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Latency: latency, Error: err.Error()}
+	}
+	if len(records) == 0 {
+		return Result{Latency: latency, Error: fmt.Sprintf("no %s records for %s", monitor.RecordType, monitor.Host)}
+	}
+
+	found := make(map[string]bool, len(records))
+	for _, record := range records {
+		found[normalizeRecord(record)] = true
+	}
+
+	for _, expected := range strings.Split(monitor.ExpectedValues, ",") {
+		expected = normalizeRecord(expected)
+		if expected != "" && !found[expected] {
+			return Result{
+				Latency: latency,
+				Error:   fmt.Sprintf("expected %s record %s not found, got %s", monitor.RecordType, expected, strings.Join(records, ", ")),
+			}
+		}
+	}
+
+	return Result{Success: true, Latency: latency}
+}
+
+func lookup(ctx context.Context, resolver *net.Resolver, recordType string, host string) ([]string, error) {
+	switch recordType {
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		// Hosts without a CNAME record come back as their own name
+		if normalizeRecord(cname) == normalizeRecord(host) {
+			return nil, nil
+		}
+		return []string{cname}, nil
+	default:
+		ips, err := resolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+		return records, nil
+	}
+}
+
+// dnsResolver returns a resolver querying the given server, or the system
+// resolver when no server is configured
+func dnsResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+func normalizeRecord(record string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(record)), ".")
+}
+
+// TLSChecker performs a verified TLS handshake and warns when the leaf
+// certificate expires within the monitor's warning window
+type TLSChecker struct {
+	// Config is the base TLS configuration, e.g., to trust a self-hosted CA
+	Config *tls.Config
+}
+
+func (TLSChecker) Validate(monitor models.Monitor) error {
+	if monitor.Host == "" {
+		return errors.New("host is required for tls monitors")
+	}
+	if monitor.ExpiryWarningDays < 0 {
+		return errors.New("expiry_warning_days must not be negative")
+	}
+	return nil
+}
+
+func (t TLSChecker) Check(ctx context.Context, monitor models.Monitor) Result {
+	config := &tls.Config{}
+	if t.Config != nil {
+		config = t.Config.Clone()
+	}
+	config.ServerName = monitor.Host
+	dialer := tls.Dialer{Config: config}
+
+	target := monitor
+	if target.Port == 0 {
+		target.Port = 443
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(target))
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Latency: latency, Error: err.Error()}
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return Result{Latency: latency, Error: "server presented no certificate"}
+	}
+
+	daysLeft := int(time.Until(certs[0].NotAfter).Hours() / 24)
+	if daysLeft < monitor.ExpiryWarningDays {
+		return Result{
+			Success: true,
+			Warning: true,
+			Latency: latency,
+			Error:   fmt.Sprintf("certificate expires in %d days (%s)", daysLeft, certs[0].NotAfter.Format(time.RFC3339)),
+		}
+	}
+
+	return Result{Success: true, Latency: latency}
+}
+
+func validateHostPort(monitor models.Monitor) error {
+	if monitor.Host == "" {
+		return fmt.Errorf("host is required for %s monitors", monitor.Type)
+	}
+	if monitor.Port < 1 || monitor.Port > 65535 {
+		return fmt.Errorf("port is required for %s monitors", monitor.Type)
+	}
+	return nil
+}
+
+func hostPort(monitor models.Monitor) string {
+	return net.JoinHostPort(monitor.Host, strconv.Itoa(monitor.Port))
+}
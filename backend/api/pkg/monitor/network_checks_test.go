@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+)
+
+func TestTCPChecker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// A port nothing listens on anymore
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	open := TCPChecker{}.Check(context.Background(), models.Monitor{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port})
+	if !open.Success {
+		t.Errorf("listening port: check failed with %q", open.Error)
+	}
+
+	refused := TCPChecker{}.Check(context.Background(), models.Monitor{Host: "127.0.0.1", Port: closedPort})
+	if refused.Success || !strings.Contains(refused.Error, "refused") {
+		t.Errorf("closed port: got success %v, error %q", refused.Success, refused.Error)
+	}
+}
+
+func TestDNSChecker(t *testing.T) {
+	tests := []struct {
+		name      string
+		monitor   models.Monitor
+		wantOK    bool
+		wantError string
+	}{
+		{"A record", models.Monitor{Host: "localhost", RecordType: "A"}, true, ""},
+		{"expected A record", models.Monitor{Host: "localhost", RecordType: "A", ExpectedValues: "127.0.0.1"}, true, ""},
+		{"missing A record", models.Monitor{Host: "localhost", RecordType: "A", ExpectedValues: "127.0.0.1,10.0.0.1"}, false, "expected A record 10.0.0.1 not found"},
+		// The resolver echoes names that have no CNAME record
+		{"no CNAME record", models.Monitor{Host: "localhost", RecordType: "CNAME"}, false, "no CNAME records for localhost"},
+		{"unreachable resolver", models.Monitor{Host: "status.invalid", RecordType: "A", Resolver: "127.0.0.1:1"}, false, ""},
+	}
+
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		result := DNSChecker{}.Check(ctx, tt.monitor)
+		cancel()
+
+		if result.Success != tt.wantOK || !strings.Contains(result.Error, tt.wantError) {
+			t.Errorf("%s: got success %v, error %q, want %v, %q", tt.name, result.Success, result.Error, tt.wantOK, tt.wantError)
+		}
+	}
+}
+
+func TestTLSCheckerWarnsBeforeExpiry(t *testing.T) {
+	// A certificate for 127.0.0.1 that expires in 5 days
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(5*24*time.Hour + time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	// newServer starts a TLS server that keeps the refused handshakes quiet
+	newServer := func(config *tls.Config) *httptest.Server {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = config
+		server.Config.ErrorLog = log.New(io.Discard, "", 0)
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	expiring := newServer(&tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}})
+	// httptest's own certificate is valid for decades
+	lasting := newServer(nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	roots.AddCert(lasting.Certificate())
+	checker := TLSChecker{Config: &tls.Config{RootCAs: roots}}
+
+	port := func(server *httptest.Server) int {
+		return server.Listener.Addr().(*net.TCPAddr).Port
+	}
+
+	tests := []struct {
+		name        string
+		server      *httptest.Server
+		warningDays int
+		checker     TLSChecker
+		wantOK      bool
+		wantWarning bool
+		wantError   string
+	}{
+		{"within the warning window", expiring, 14, checker, true, true, "certificate expires in 5 days"},
+		{"outside of the warning window", expiring, 3, checker, true, false, ""},
+		{"warning turned off", expiring, 0, checker, true, false, ""},
+		{"long-lived certificate", lasting, 14, checker, true, false, ""},
+		{"untrusted certificate", lasting, 14, TLSChecker{}, false, false, "certificate"},
+	}
+
+	for _, tt := range tests {
+		result := tt.checker.Check(context.Background(), models.Monitor{
+			Host:              "127.0.0.1",
+			Port:              port(tt.server),
+			ExpiryWarningDays: tt.warningDays,
+		})
+		if result.Success != tt.wantOK || result.Warning != tt.wantWarning || !strings.Contains(result.Error, tt.wantError) {
+			t.Errorf("%s: got success %v, warning %v, error %q", tt.name, result.Success, result.Warning, result.Error)
+		}
+	}
+
+	// A near expiry degrades the service right away
+	monitor := models.Monitor{CurrentStatus: models.ServiceStatusOperational, DegradedThreshold: 3, RecoveryThreshold: 1}
+	applyResult(&monitor, checker.Check(context.Background(), models.Monitor{Host: "127.0.0.1", Port: port(expiring), ExpiryWarningDays: 14}))
+	if monitor.CurrentStatus != models.ServiceStatusDegraded {
+		t.Errorf("expiring certificate: monitor reports %s, want degraded", monitor.CurrentStatus)
+	}
+}
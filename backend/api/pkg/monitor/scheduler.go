@@ -0,0 +1,319 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// tickInterval is how often the scheduler looks for monitors that are due
+	tickInterval = 5 * time.Second
+	// maxConcurrentChecks bounds how many checks run at the same time
+	maxConcurrentChecks = 10
+)
+
+// Scheduler periodically runs the enabled monitors and moves their services'
+// status according to the monitors' failure thresholds
+type Scheduler struct {
+	db  *gorm.DB
+	sem chan struct{}
+
+	mu       sync.Mutex
+	inflight map[uint]bool
+}
+
+func NewScheduler(database *gorm.DB) *Scheduler {
+	return &Scheduler{
+		db:       database,
+		sem:      make(chan struct{}, maxConcurrentChecks),
+		inflight: make(map[uint]bool),
+	}
+}
+
+// Start runs the scheduler in the background until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			s.RunDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue starts a check for every enabled monitor whose interval has elapsed
+func (s *Scheduler) RunDue(ctx context.Context) {
+	var monitors []models.Monitor
+	if err := s.db.Where("enabled = ?", true).Find(&monitors).Error; err != nil {
+		log.Printf("monitor: failed to load monitors: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, m := range monitors {
+		if !isDue(m, now) || !s.claim(m.ID) {
+			continue
+		}
+
+		go func(m models.Monitor) {
+			defer s.release(m.ID)
+
+			s.sem <- struct{}{}
+			defer func() { <-s.sem }()
+
+			if err := s.RunMonitor(ctx, m); err != nil {
+				log.Printf("monitor: check %d failed to record: %v", m.ID, err)
+			}
+		}(m)
+	}
+}
+
+// RunMonitor runs a single check for the monitor and records its result
+func (s *Scheduler) RunMonitor(ctx context.Context, monitor models.Monitor) error {
+	checker, ok := checkers[monitor.Type]
+	if !ok {
+		return fmt.Errorf("unknown monitor type %q", monitor.Type)
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, time.Duration(monitor.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	return recordResult(s.db, &monitor, checker.Check(checkCtx, monitor))
+}
+
+// recordResult persists a check result, updates the monitor's state and, when
+// the monitor's status changes, the status of its service. The monitor is
+// re-read under a row lock, so that results recorded at the same time, e.g., a
+// heartbeat ping and a missed heartbeat, each count once and in turn.
+func recordResult(database *gorm.DB, monitor *models.Monitor, result Result) error {
+	now := time.Now()
+
+	var changed bool
+	var publish func()
+	err := database.Transaction(func(tx *gorm.DB) error {
+		current, err := lockMonitor(tx.Where("id = ?", monitor.ID))
+		if err != nil {
+			return err
+		}
+		*monitor = current
+
+		// The monitor may have been disabled, or a heartbeat pinged, while it was checked
+		if checker, ok := checkers[current.Type].(dueChecker); !current.Enabled || (ok && !checker.Due(current, now)) {
+			return nil
+		}
+
+		changed, publish, err = saveResult(tx, monitor, result, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return finishResult(database, monitor, changed, publish)
+}
+
+// lockMonitor loads the monitor matching the query and locks its row until
+// the end of the transaction
+func lockMonitor(tx *gorm.DB) (models.Monitor, error) {
+	var monitor models.Monitor
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&monitor).Error
+	return monitor, err
+}
+
+// saveResult stores a check result of the locked monitor and its new state,
+// and opens or resolves its incident when its status changed. publish sends
+// the incident event once the transaction is committed.
+func saveResult(tx *gorm.DB, monitor *models.Monitor, result Result, now time.Time) (changed bool, publish func(), err error) {
+	checkResult := models.MonitorCheckResult{
+		MonitorID:  monitor.ID,
+		Success:    result.Success,
+		Warning:    result.Warning,
+		StatusCode: result.StatusCode,
+		LatencyMs:  result.Latency.Milliseconds(),
+		Error:      result.Error,
+		CheckedAt:  now,
+	}
+	if err := tx.Create(&checkResult).Error; err != nil {
+		return false, nil, err
+	}
+
+	previousStatus := monitor.CurrentStatus
+	applyResult(monitor, result)
+	monitor.LastCheckedAt = &now
+	monitor.LastError = result.Error
+
+	if err := tx.Model(monitor).Updates(map[string]interface{}{
+		"current_status":        monitor.CurrentStatus,
+		"consecutive_failures":  monitor.ConsecutiveFailures,
+		"consecutive_successes": monitor.ConsecutiveSuccesses,
+		"last_checked_at":       now,
+		"last_error":            result.Error,
+	}).Error; err != nil {
+		return false, nil, err
+	}
+
+	// Leave manual status changes alone until the monitor has something new to say
+	if monitor.CurrentStatus == previousStatus {
+		return false, nil, nil
+	}
+
+	if monitor.OpenIncident {
+		if publish, err = syncIncident(tx, monitor); err != nil {
+			return false, nil, err
+		}
+	}
+	return true, publish, nil
+}
+
+// finishResult publishes the incident event of a committed result and moves
+// the service status when the monitor's status changed
+func finishResult(database *gorm.DB, monitor *models.Monitor, changed bool, publish func()) error {
+	if publish != nil {
+		publish()
+	}
+	if !changed {
+		return nil
+	}
+	return syncServiceStatus(database, monitor.ServiceID, statusCause(*monitor))
+}
+
+// syncServiceStatus sets the service to the worst status reported by its
+// enabled monitors, or refreshes it when its organization derives statuses
+func syncServiceStatus(database *gorm.DB, serviceID string, cause services.StatusCause) error {
+	var service models.Service
+	if err := database.Where("id = ?", serviceID).First(&service).Error; err != nil {
+		return err
+	}
+
+	var org models.Organization
+	if err := database.Where("id = ?", service.OrganizationID).First(&org).Error; err != nil {
+		return err
+	}
+
+	// Derived statuses already take the monitors into account
+	if org.StatusMode == models.StatusModeDerived {
+		return services.RefreshServiceStatus(database, serviceID, cause)
+	}
+
+	var monitors []models.Monitor
+	if err := database.Where("service_id = ? AND enabled = ?", serviceID, true).Find(&monitors).Error; err != nil {
+		return err
+	}
+
+	statuses := make([]string, 0, len(monitors))
+	for _, m := range monitors {
+		statuses = append(statuses, m.CurrentStatus)
+	}
+
+	if service.Status == models.ServiceStatusUnderMaintenance {
+		// During maintenance the monitors only decide the status restored afterwards
+		if err := database.Model(&models.Maintenance{}).
+			Where("service_id = ? AND status = ?", serviceID, "in_progress").
+			Update("previous_service_status", services.WorstStatus(statuses...)).Error; err != nil {
+			return err
+		}
+	} else if err := services.SetServiceStatus(database, &service, services.WorstStatus(statuses...), cause); err != nil {
+		return err
+	}
+
+	return services.RefreshServiceStatus(database, serviceID, cause)
+}
+
+// statusCause attributes a service status change to the monitor's latest result
+func statusCause(monitor models.Monitor) services.StatusCause {
+	reason := fmt.Sprintf("Monitor %q reported %s", monitor.Name, monitor.CurrentStatus)
+	if monitor.LastError != "" {
+		reason += ": " + monitor.LastError
+	}
+	return services.StatusCause{Source: models.StatusSourceMonitor, Reason: reason}
+}
+
+// applyResult updates the monitor's consecutive counters and the status it reports
+func applyResult(monitor *models.Monitor, result Result) {
+	// Warnings degrade the service right away and restart the recovery count
+	if result.Success && result.Warning {
+		monitor.ConsecutiveFailures = 0
+		monitor.ConsecutiveSuccesses = 0
+		monitor.CurrentStatus = models.ServiceStatusDegraded
+		return
+	}
+
+	if result.Success {
+		monitor.ConsecutiveFailures = 0
+		monitor.ConsecutiveSuccesses++
+		if monitor.ConsecutiveSuccesses >= monitor.RecoveryThreshold {
+			monitor.CurrentStatus = models.ServiceStatusOperational
+		}
+		return
+	}
+
+	monitor.ConsecutiveSuccesses = 0
+	monitor.ConsecutiveFailures++
+	monitor.CurrentStatus = statusForFailures(*monitor)
+}
+
+// statusForFailures maps the monitor's consecutive failures to a service status.
+// Below every threshold the monitor keeps reporting its current status.
+func statusForFailures(monitor models.Monitor) string {
+	failures := monitor.ConsecutiveFailures
+
+	switch {
+	case monitor.MajorOutageThreshold > 0 && failures >= monitor.MajorOutageThreshold:
+		return models.ServiceStatusMajorOutage
+	case monitor.PartialOutageThreshold > 0 && failures >= monitor.PartialOutageThreshold:
+		return models.ServiceStatusPartialOutage
+	case monitor.DegradedThreshold > 0 && failures >= monitor.DegradedThreshold:
+		return models.ServiceStatusDegraded
+	}
+
+	return monitor.CurrentStatus
+}
+
+// dueChecker is implemented by checkers that are not simply run every interval
+type dueChecker interface {
+	Due(monitor models.Monitor, now time.Time) bool
+}
+
+func isDue(monitor models.Monitor, now time.Time) bool {
+	if checker, ok := checkers[monitor.Type].(dueChecker); ok {
+		return checker.Due(monitor, now)
+	}
+	if monitor.LastCheckedAt == nil {
+		return true
+	}
+	interval := time.Duration(monitor.IntervalSeconds) * time.Second
+	return !now.Before(monitor.LastCheckedAt.Add(interval))
+}
+
+func (s *Scheduler) claim(id uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inflight[id] {
+		return false
+	}
+	s.inflight[id] = true
+	return true
+}
+
+func (s *Scheduler) release(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inflight, id)
+}
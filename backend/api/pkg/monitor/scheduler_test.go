@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB returns an in-memory database with the tables monitors touch
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Every connection to :memory: opens a database of its own
+	sqlDB, err := database.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.AutoMigrate(
+		&models.Organization{},
+		&models.Service{},
+		&models.Incident{},
+		&models.IncidentService{},
+		&models.IncidentUpdate{},
+		&models.Maintenance{},
+		&models.Monitor{},
+		&models.MonitorCheckResult{},
+		&models.ServiceStatusChange{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+// createMonitor stores an enabled monitor for a new operational service
+func createMonitor(t *testing.T, database *gorm.DB, monitor models.Monitor) models.Monitor {
+	t.Helper()
+
+	org := models.Organization{ID: "org", ClerkOrgID: "org_1", Name: "Acme", Slug: "acme"}
+	if err := database.FirstOrCreate(&org).Error; err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	service := models.Service{Name: "API", Status: models.ServiceStatusOperational, UserID: "user_1", OrganizationID: org.ID}
+	if err := database.Create(&service).Error; err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+
+	monitor.Enabled = true
+	monitor.ServiceID = fmt.Sprint(service.ID)
+	monitor.OrganizationID = org.ID
+	if monitor.Name == "" {
+		monitor.Name = "API health"
+	}
+	if monitor.CurrentStatus == "" {
+		monitor.CurrentStatus = models.ServiceStatusOperational
+	}
+	if err := database.Create(&monitor).Error; err != nil {
+		t.Fatalf("create monitor: %v", err)
+	}
+	return monitor
+}
+
+func serviceStatus(t *testing.T, database *gorm.DB, serviceID string) string {
+	t.Helper()
+
+	var service models.Service
+	if err := database.Where("id = ?", serviceID).First(&service).Error; err != nil {
+		t.Fatalf("load service: %v", err)
+	}
+	return service.Status
+}
+
+var (
+	pass = Result{Success: true}
+	fail = Result{Error: "unexpected status code 503"}
+	warn = Result{Success: true, Warning: true, Error: "certificate expires in 3 days"}
+)
+
+func TestApplyResult(t *testing.T) {
+	thresholds := models.Monitor{
+		DegradedThreshold:      1,
+		PartialOutageThreshold: 3,
+		MajorOutageThreshold:   5,
+		RecoveryThreshold:      2,
+		CurrentStatus:          models.ServiceStatusOperational,
+	}
+
+	tests := []struct {
+		name    string
+		monitor models.Monitor
+		results []Result
+		want    []string
+	}{
+		{
+			name:    "failures climb the thresholds",
+			monitor: thresholds,
+			results: []Result{fail, fail, fail, fail, fail, fail},
+			want:    []string{"degraded", "degraded", "partial_outage", "partial_outage", "major_outage", "major_outage"},
+		},
+		{
+			name:    "recovery needs consecutive successes",
+			monitor: thresholds,
+			results: []Result{fail, fail, fail, pass, fail, pass, pass},
+			want:    []string{"degraded", "degraded", "partial_outage", "partial_outage", "degraded", "degraded", "operational"},
+		},
+		{
+			name: "disabled levels are skipped",
+			monitor: models.Monitor{
+				MajorOutageThreshold: 2,
+				RecoveryThreshold:    1,
+				CurrentStatus:        models.ServiceStatusOperational,
+			},
+			results: []Result{fail, fail, pass},
+			want:    []string{"operational", "major_outage", "operational"},
+		},
+		{
+			name:    "warnings degrade right away and restart the recovery",
+			monitor: thresholds,
+			results: []Result{warn, pass, warn, pass, pass},
+			want:    []string{"degraded", "degraded", "degraded", "degraded", "operational"},
+		},
+	}
+
+	for _, tt := range tests {
+		monitor := tt.monitor
+		for i, result := range tt.results {
+			applyResult(&monitor, result)
+			if monitor.CurrentStatus != tt.want[i] {
+				t.Errorf("%s: after result %d the monitor reports %s, want %s", tt.name, i+1, monitor.CurrentStatus, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestRecordResultMovesTheServiceStatus(t *testing.T) {
+	database := openTestDB(t)
+	monitor := createMonitor(t, database, models.Monitor{
+		Type:                   "http",
+		DegradedThreshold:      1,
+		PartialOutageThreshold: 2,
+		RecoveryThreshold:      2,
+	})
+
+	steps := []struct {
+		result Result
+		want   string
+	}{
+		{fail, models.ServiceStatusDegraded},
+		{fail, models.ServiceStatusPartialOutage},
+		{pass, models.ServiceStatusPartialOutage},
+		{pass, models.ServiceStatusOperational},
+	}
+
+	for i, step := range steps {
+		if err := recordResult(database, &monitor, step.result); err != nil {
+			t.Fatalf("step %d: recordResult: %v", i+1, err)
+		}
+		if status := serviceStatus(t, database, monitor.ServiceID); status != step.want {
+			t.Errorf("step %d: service is %s, want %s", i+1, status, step.want)
+		}
+	}
+
+	var stored models.Monitor
+	database.First(&stored, monitor.ID)
+	if stored.CurrentStatus != models.ServiceStatusOperational || stored.ConsecutiveSuccesses != 2 || stored.LastCheckedAt == nil {
+		t.Errorf("stored monitor state = %s, %d successes, checked at %v", stored.CurrentStatus, stored.ConsecutiveSuccesses, stored.LastCheckedAt)
+	}
+
+	var results int64
+	database.Model(&models.MonitorCheckResult{}).Where("monitor_id = ?", monitor.ID).Count(&results)
+	if results != int64(len(steps)) {
+		t.Errorf("stored %d check results, want %d", results, len(steps))
+	}
+
+	// One status change per transition, attributed to the monitor
+	var changes []models.ServiceStatusChange
+	database.Where("service_id = ?", monitor.ServiceID).Order("id").Find(&changes)
+	if len(changes) != 3 {
+		t.Fatalf("recorded %d status changes, want 3", len(changes))
+	}
+	for _, change := range changes {
+		if change.Source != models.StatusSourceMonitor {
+			t.Errorf("status change to %s has source %s", change.NewStatus, change.Source)
+		}
+	}
+}
+
+func TestRecordResultOpensAndResolvesAnIncident(t *testing.T) {
+	database := openTestDB(t)
+	monitor := createMonitor(t, database, models.Monitor{
+		Type:                 "http",
+		MajorOutageThreshold: 2,
+		RecoveryThreshold:    1,
+		OpenIncident:         true,
+	})
+
+	for _, result := range []Result{fail, fail} {
+		if err := recordResult(database, &monitor, result); err != nil {
+			t.Fatalf("recordResult: %v", err)
+		}
+	}
+
+	var incident models.Incident
+	if err := database.Preload("Services").First(&incident).Error; err != nil {
+		t.Fatalf("no incident opened: %v", err)
+	}
+	if incident.Status != models.IncidentStatusInvestigating || incident.Severity != "critical" {
+		t.Errorf("incident is %s with severity %s", incident.Status, incident.Severity)
+	}
+	if len(incident.Services) != 1 || incident.Services[0].Impact != models.ServiceStatusMajorOutage {
+		t.Errorf("incident services = %+v", incident.Services)
+	}
+	if monitor.IncidentID != fmt.Sprint(incident.ID) {
+		t.Errorf("monitor points to incident %q, want %d", monitor.IncidentID, incident.ID)
+	}
+
+	if err := recordResult(database, &monitor, pass); err != nil {
+		t.Fatalf("recordResult: %v", err)
+	}
+
+	database.First(&incident, incident.ID)
+	if incident.Status != models.IncidentStatusResolved {
+		t.Errorf("incident is %s after the monitor recovered, want resolved", incident.Status)
+	}
+	var incidents int64
+	database.Model(&models.Incident{}).Count(&incidents)
+	if incidents != 1 || monitor.IncidentID != "" {
+		t.Errorf("%d incidents, monitor incident %q", incidents, monitor.IncidentID)
+	}
+}
+
+func TestRemovingAFailingMonitorRestoresTheService(t *testing.T) {
+	database := openTestDB(t)
+	monitor := createMonitor(t, database, models.Monitor{
+		Type:                 "http",
+		MajorOutageThreshold: 1,
+		RecoveryThreshold:    1,
+		OpenIncident:         true,
+	})
+	if err := recordResult(database, &monitor, fail); err != nil {
+		t.Fatalf("recordResult: %v", err)
+	}
+	incidentID := monitor.IncidentID
+
+	// What DeleteMonitor does with the monitor's incident and service
+	publish, err := resolveIncident(database, &monitor, "API health was deleted.")
+	if err != nil {
+		t.Fatalf("resolveIncident: %v", err)
+	}
+	if publish == nil {
+		t.Error("resolving the incident publishes no event")
+	}
+	database.Delete(&monitor)
+	refreshService(database, monitor.ServiceID, true, statusCause(monitor))
+
+	var incident models.Incident
+	database.Where("id = ?", incidentID).First(&incident)
+	if incident.Status != models.IncidentStatusResolved || monitor.IncidentID != "" {
+		t.Errorf("incident is %s, monitor incident %q", incident.Status, monitor.IncidentID)
+	}
+	if status := serviceStatus(t, database, monitor.ServiceID); status != models.ServiceStatusOperational {
+		t.Errorf("service is %s without its failing monitor, want operational", status)
+	}
+}
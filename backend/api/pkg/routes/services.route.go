@@ -1,8 +1,17 @@
 package routes
 
 import (
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/chat"
 	"github.com/apsinghdev/PopenStatus/api/pkg/handlers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/monitor"
 	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/slack"
+	"github.com/apsinghdev/PopenStatus/api/pkg/slo"
+	"github.com/apsinghdev/PopenStatus/api/pkg/subscribers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/uptime"
+	"github.com/apsinghdev/PopenStatus/api/pkg/webhooks"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -11,17 +20,112 @@ func ServiceRoutes(app *fiber.App) {
 	servicesGroup := api.Group("/services")
 	incidentsGroup := api.Group("/incidents")
 	orgGroup := api.Group("/organizations")
+	monitorsGroup := api.Group("/monitors")
+	heartbeatsGroup := api.Group("/heartbeats")
+	slosGroup := api.Group("/slos")
+	maintenancesGroup := api.Group("/maintenances")
 
-	servicesGroup.Post("/create", services.HandleCreateService)
-	servicesGroup.Get("/list", services.ListServices)
-	servicesGroup.Delete("/:id", services.DeleteService)
-	servicesGroup.Put("/:id", services.UpdateService)
+	apiKeysGroup := api.Group("/api-keys")
+	webhooksGroup := api.Group("/webhooks")
+	subscribersGroup := api.Group("/subscribers")
+	chatGroup := api.Group("/chat-integrations")
+	slackGroup := api.Group("/slack")
 
-	incidentsGroup.Post("/create", services.CreateIncident)
-	incidentsGroup.Get("/list", services.ListIncidents)
-	incidentsGroup.Delete("/delete/:id", services.DeleteIncident)
-	incidentsGroup.Put("/update/:id", services.UpdateIncident)
+	// Every route outside the slug-based status page endpoints acts on the
+	// caller's active organization. Reads only need a member, changes require
+	// a role: responders handle incidents and maintenances, admins manage the
+	// organization's setup. API keys need the scope of the resource instead.
+	requireAuth := auth.Middleware()
+	admin := auth.RequireRole(auth.RoleAdmin)
+	manageServices := auth.Authorize(auth.RoleAdmin, models.ScopeServicesWrite)
+	updateServices := auth.Authorize(auth.RoleResponder, models.ScopeServicesWrite)
+	handleIncidents := auth.Authorize(auth.RoleResponder, models.ScopeIncidentsWrite)
+	deleteIncidents := auth.Authorize(auth.RoleAdmin, models.ScopeIncidentsWrite)
+	handleMaintenances := auth.Authorize(auth.RoleResponder, models.ScopeMaintenancesWrite)
+	manageMonitors := auth.Authorize(auth.RoleAdmin, models.ScopeMonitorsWrite)
+	manageSLOs := auth.Authorize(auth.RoleAdmin, models.ScopeSLOsWrite)
+
+	servicesGroup.Post("/create", requireAuth, manageServices, services.HandleCreateService)
+	servicesGroup.Get("/list", requireAuth, services.ListServices)
+	servicesGroup.Delete("/:id", requireAuth, manageServices, services.DeleteService)
+	servicesGroup.Put("/:id", requireAuth, updateServices, services.UpdateService)
+	servicesGroup.Delete("/:id/override", requireAuth, updateServices, services.ClearServiceOverride)
+	servicesGroup.Get("/:id/history", requireAuth, services.GetServiceHistory)
+
+	incidentsGroup.Post("/create", requireAuth, handleIncidents, services.CreateIncident)
+	incidentsGroup.Get("/list", requireAuth, services.ListIncidents)
+	incidentsGroup.Delete("/delete/:id", requireAuth, deleteIncidents, services.DeleteIncident)
+	incidentsGroup.Put("/update/:id", requireAuth, handleIncidents, services.UpdateIncident)
+	incidentsGroup.Post("/:id/reopen", requireAuth, handleIncidents, services.HandleReopenIncident)
+	incidentsGroup.Post("/:id/updates", requireAuth, handleIncidents, services.CreateIncidentUpdate)
+	incidentsGroup.Get("/:id/updates", requireAuth, services.ListIncidentUpdates)
+	incidentsGroup.Put("/:id/updates/:updateId", requireAuth, handleIncidents, services.EditIncidentUpdate)
+
+	maintenancesGroup.Post("/create", requireAuth, handleMaintenances, services.CreateMaintenance)
+	maintenancesGroup.Get("/list", requireAuth, services.ListMaintenances)
+	maintenancesGroup.Put("/:id", requireAuth, handleMaintenances, services.UpdateMaintenance)
+	maintenancesGroup.Post("/:id/cancel", requireAuth, handleMaintenances, services.CancelMaintenance)
+
+	monitorsGroup.Post("/create", requireAuth, manageMonitors, monitor.CreateMonitor)
+	monitorsGroup.Get("/list", requireAuth, monitor.ListMonitors)
+	monitorsGroup.Put("/:id", requireAuth, manageMonitors, monitor.UpdateMonitor)
+	monitorsGroup.Delete("/:id", requireAuth, manageMonitors, monitor.DeleteMonitor)
+	monitorsGroup.Get("/:id/results", requireAuth, monitor.ListCheckResults)
+
+	// Jobs ping with whatever their HTTP client makes easiest
+	heartbeatsGroup.Get("/:token/:kind?", monitor.HandleHeartbeatPing)
+	heartbeatsGroup.Post("/:token/:kind?", monitor.HandleHeartbeatPing)
+
+	slosGroup.Post("/create", requireAuth, manageSLOs, slo.CreateSLO)
+	slosGroup.Get("/list", requireAuth, slo.ListSLOs)
+	slosGroup.Put("/:id", requireAuth, manageSLOs, slo.UpdateSLO)
+	slosGroup.Delete("/:id", requireAuth, manageSLOs, slo.DeleteSLO)
+	slosGroup.Get("/:id/budget", requireAuth, slo.GetSLOBudget)
+	slosGroup.Get("/:id/alerts", requireAuth, slo.ListSLOAlerts)
 
 	orgGroup.Get("/:slug/status", services.GetOrganizationStatus)
+	orgGroup.Get("/:slug/events", services.StreamOrganizationStatus)
+	orgGroup.Get("/:slug/services/:id/uptime", uptime.GetServiceUptime)
 	orgGroup.Get("/list", handlers.ListOrganizations)
+	orgGroup.Post("/:slug/subscribers", subscribers.Subscribe)
+	orgGroup.Put("/settings", requireAuth, admin, handlers.UpdateOrganizationSettings)
+	orgGroup.Get("/invitations", requireAuth, admin, handlers.ListInvitations)
+	orgGroup.Get("/webhook-events", requireAuth, admin, handlers.ListWebhookEvents)
+
+	apiKeysGroup.Post("/create", requireAuth, admin, handlers.CreateAPIKey)
+	apiKeysGroup.Get("/list", requireAuth, admin, handlers.ListAPIKeys)
+	apiKeysGroup.Delete("/:id", requireAuth, admin, handlers.RevokeAPIKey)
+
+	webhooksGroup.Post("/create", requireAuth, admin, webhooks.CreateEndpoint)
+	webhooksGroup.Get("/list", requireAuth, admin, webhooks.ListEndpoints)
+	webhooksGroup.Put("/:id", requireAuth, admin, webhooks.UpdateEndpoint)
+	webhooksGroup.Delete("/:id", requireAuth, admin, webhooks.DeleteEndpoint)
+	webhooksGroup.Get("/:id/deliveries", requireAuth, admin, webhooks.ListDeliveries)
+
+	chatGroup.Post("/create", requireAuth, admin, chat.CreateIntegration)
+	chatGroup.Get("/list", requireAuth, admin, chat.ListIntegrations)
+	chatGroup.Put("/:id", requireAuth, admin, chat.UpdateIntegration)
+	chatGroup.Delete("/:id", requireAuth, admin, chat.DeleteIntegration)
+	chatGroup.Post("/:id/test", requireAuth, admin, chat.TestIntegration)
+
+	// Called by Slack, which signs its requests instead of sending a session
+	slackGroup.Post("/commands", slack.HandleCommand)
+	slackGroup.Post("/interactions", slack.HandleInteraction)
+	slackGroup.Post("/link-code", requireAuth, admin, slack.CreateLinkCode)
+	slackGroup.Put("/members/:id", requireAuth, admin, slack.LinkMember)
+
+	// Links in subscriber emails. Only a POST unsubscribes, sent by the
+	// link's page or by mail clients as a one-click unsubscribe.
+	subscribersGroup.Get("/confirm/:token", subscribers.ConfirmSubscription)
+	subscribersGroup.Get("/unsubscribe/:token", subscribers.ConfirmUnsubscribe)
+	subscribersGroup.Post("/unsubscribe/:token", subscribers.Unsubscribe)
+	subscribersGroup.Get("/preferences/:token", subscribers.GetPreferences)
+	subscribersGroup.Put("/preferences/:token", subscribers.UpdatePreferences)
+	subscribersGroup.Get("/list", requireAuth, admin, subscribers.ListSubscribers)
+}
+
+func RealtimeRoutes(app *fiber.App) {
+	wsGroup := app.Group("/ws")
+
+	wsGroup.Get("/organizations/:slug", services.RequireWebSocketUpgrade, services.StreamOrganizationEvents)
 }
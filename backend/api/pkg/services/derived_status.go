@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultOverrideDuration is how long a manual override lasts when no expiry is given
+	defaultOverrideDuration = time.Hour
+	// overrideCheckInterval is how often expired overrides are cleared
+	overrideCheckInterval = 30 * time.Second
+)
+
+// ComputeServiceStatus returns the worst of the impacts of the service's
+// unresolved incidents, the statuses of its monitors and its active
+// maintenance. Monitors are ignored while the service is under maintenance.
+func ComputeServiceStatus(database *gorm.DB, serviceID string) (string, error) {
+	var statuses []string
+	if err := database.Model(&models.IncidentService{}).
+		Joins("JOIN incidents ON incidents.id = incident_services.incident_id").
+		Where("incident_services.service_id = ? AND incidents.status <> ? AND incidents.deleted_at IS NULL",
+			serviceID, models.IncidentStatusResolved).
+		Pluck("incident_services.impact", &statuses).Error; err != nil {
+		return "", err
+	}
+
+	var maintenances int64
+	if err := database.Model(&models.Maintenance{}).
+		Where("service_id = ? AND status = ?", serviceID, "in_progress").
+		Count(&maintenances).Error; err != nil {
+		return "", err
+	}
+
+	if maintenances > 0 {
+		statuses = append(statuses, models.ServiceStatusUnderMaintenance)
+	} else {
+		var monitorStatuses []string
+		if err := database.Model(&models.Monitor{}).
+			Where("service_id = ? AND enabled = ?", serviceID, true).
+			Pluck("current_status", &monitorStatuses).Error; err != nil {
+			return "", err
+		}
+		statuses = append(statuses, monitorStatuses...)
+	}
+
+	return WorstStatus(statuses...), nil
+}
+
+// EffectiveStatus returns the status a service shows in derived mode: its
+// manual override while active, its computed status otherwise
+func EffectiveStatus(service models.Service, now time.Time) string {
+	if overrideActive(service, now) {
+		return service.ManualStatus
+	}
+	return service.ComputedStatus
+}
+
+// RefreshServiceStatus recomputes the service's status and, when its
+// organization derives statuses, applies the effective one on behalf of cause
+func RefreshServiceStatus(database *gorm.DB, serviceID string, cause StatusCause) error {
+	var service models.Service
+	if err := database.Where("id = ?", serviceID).First(&service).Error; err != nil {
+		return err
+	}
+
+	var org models.Organization
+	if err := database.Where("id = ?", service.OrganizationID).First(&org).Error; err != nil {
+		return err
+	}
+
+	computed, err := ComputeServiceStatus(database, serviceID)
+	if err != nil {
+		return err
+	}
+
+	if computed != service.ComputedStatus {
+		if err := database.Model(&service).Update("computed_status", computed).Error; err != nil {
+			return err
+		}
+		service.ComputedStatus = computed
+	}
+
+	if org.StatusMode != models.StatusModeDerived {
+		return nil
+	}
+
+	return SetServiceStatus(database, &service, EffectiveStatus(service, time.Now()), cause)
+}
+
+// refreshServiceStatuses refreshes the given services, logging the ones that fail
+func refreshServiceStatuses(database *gorm.DB, serviceIDs []string, cause StatusCause) {
+	for _, serviceID := range serviceIDs {
+		if err := RefreshServiceStatus(database, serviceID, cause); err != nil {
+			log.Printf("status: failed to refresh service %s: %v", serviceID, err)
+		}
+	}
+}
+
+// incidentServiceIDs returns the IDs of the services an incident affects
+func incidentServiceIDs(database *gorm.DB, incidentID uint) []string {
+	var serviceIDs []string
+	database.Model(&models.IncidentService{}).Where("incident_id = ?", incidentID).Pluck("service_id", &serviceIDs)
+	return serviceIDs
+}
+
+func overrideActive(service models.Service, now time.Time) bool {
+	if service.ManualStatus == "" {
+		return false
+	}
+	return service.ManualStatusExpiresAt == nil || now.Before(*service.ManualStatusExpiresAt)
+}
+
+// OverrideExpirer clears manual overrides once they expire so services go
+// back to their computed status
+type OverrideExpirer struct {
+	db *gorm.DB
+}
+
+func NewOverrideExpirer(database *gorm.DB) *OverrideExpirer {
+	return &OverrideExpirer{db: database}
+}
+
+// Start runs the expirer in the background until the context is cancelled
+func (e *OverrideExpirer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(overrideCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := e.Run(time.Now()); err != nil {
+				log.Printf("status: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Run clears every override that expired by now
+func (e *OverrideExpirer) Run(now time.Time) error {
+	var services []models.Service
+	if err := e.db.Where("manual_status <> '' AND manual_status_expires_at <= ?", now).
+		Find(&services).Error; err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		cause := StatusCause{Source: models.StatusSourceSystem, Reason: "Manual override expired"}
+		if err := ClearOverride(e.db, &service, cause); err != nil {
+			log.Printf("status: failed to clear override of service %d: %v", service.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// ClearOverride removes the service's manual override and applies its status again
+func ClearOverride(database *gorm.DB, service *models.Service, cause StatusCause) error {
+	if err := database.Model(service).Updates(map[string]interface{}{
+		"manual_status":            "",
+		"manual_status_expires_at": nil,
+	}).Error; err != nil {
+		return err
+	}
+	service.ManualStatus = ""
+	service.ManualStatusExpiresAt = nil
+
+	return RefreshServiceStatus(database, fmt.Sprint(service.ID), cause)
+}
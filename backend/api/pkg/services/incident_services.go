@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// defaultImpact is used for affected services given without an impact
+const defaultImpact = models.ServiceStatusPartialOutage
+
+// AffectedService is a service affected by an incident in create and update requests
+type AffectedService struct {
+	ServiceID string `json:"service_id" validate:"required"`
+	Impact    string `json:"impact" validate:"omitempty,oneof=degraded partial_outage major_outage"`
+}
+
+// InputError is returned when a change is refused because of what was asked
+// for, as opposed to a failure to carry it out
+type InputError struct {
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return e.Message
+}
+
+// incidentServices checks that the affected services belong to the
+// organization and turns them into incident links
+func incidentServices(database *gorm.DB, org models.Organization, affected []AffectedService) ([]models.IncidentService, error) {
+	links := make([]models.IncidentService, 0, len(affected))
+	seen := make(map[string]bool)
+
+	for _, a := range affected {
+		if seen[a.ServiceID] {
+			return nil, fmt.Errorf("Service %s is listed more than once", a.ServiceID)
+		}
+		seen[a.ServiceID] = true
+
+		var service models.Service
+		if err := database.Where("id = ? AND organization_id = ?", a.ServiceID, org.ID).First(&service).Error; err != nil {
+			return nil, fmt.Errorf("Service %s not found or does not belong to the organization", a.ServiceID)
+		}
+
+		impact := a.Impact
+		if impact == "" {
+			impact = defaultImpact
+		}
+
+		links = append(links, models.IncidentService{
+			ServiceID: a.ServiceID,
+			Impact:    impact,
+		})
+	}
+
+	if len(links) == 0 {
+		return nil, errors.New("At least one affected service is required")
+	}
+
+	return links, nil
+}
+
+// CheckServiceIDs checks that the services chosen by a subscriber belong to
+// the organization
+func CheckServiceIDs(database *gorm.DB, orgID string, serviceIDs []string) error {
+	for _, serviceID := range serviceIDs {
+		var service models.Service
+		if err := database.Where("id = ? AND organization_id = ?", serviceID, orgID).First(&service).Error; err != nil {
+			return fmt.Errorf("Service %s not found or does not belong to the organization", serviceID)
+		}
+	}
+	return nil
+}
+
+// replaceIncidentServices swaps the services linked to an incident for the given ones
+func replaceIncidentServices(tx *gorm.DB, incidentID uint, links []models.IncidentService) error {
+	if err := tx.Where("incident_id = ?", incidentID).Delete(&models.IncidentService{}).Error; err != nil {
+		return err
+	}
+
+	for i := range links {
+		links[i].IncidentID = incidentID
+	}
+	return tx.Create(&links).Error
+}
+
+// loadIncident loads an incident with its affected services and its update timeline
+func loadIncident(database *gorm.DB, incidentID uint) (models.Incident, error) {
+	var incident models.Incident
+	err := database.
+		Preload("Services.Service").
+		Preload("Updates", func(db *gorm.DB) *gorm.DB {
+			return db.Order("timestamp ASC")
+		}).
+		First(&incident, incidentID).Error
+	return incident, err
+}
+
+// publishIncident notifies real-time subscribers of the incident along with
+// its affected services and update timeline
+func publishIncident(database *gorm.DB, slug string, action string, incidentID uint) {
+	incident, err := loadIncident(database, incidentID)
+	if err != nil {
+		return
+	}
+
+	events.PublishIncident(slug, action, incident)
+}
+
+// idStrings formats IDs for the string foreign keys that reference them
+func idStrings(ids []uint) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = fmt.Sprint(id)
+	}
+	return strs
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// incidentStatusOrder ranks incident statuses along the incident lifecycle
+var incidentStatusOrder = map[string]int{
+	models.IncidentStatusInvestigating: 0,
+	models.IncidentStatusIdentified:    1,
+	models.IncidentStatusMonitoring:    2,
+	models.IncidentStatusResolved:      3,
+}
+
+// incidentStatusMessages are posted for status changes that come without a message
+var incidentStatusMessages = map[string]string{
+	models.IncidentStatusInvestigating: "We are investigating this incident.",
+	models.IncidentStatusIdentified:    "The cause of this incident has been identified.",
+	models.IncidentStatusMonitoring:    "A fix has been implemented and we are monitoring the results.",
+	models.IncidentStatusResolved:      "This incident has been resolved.",
+}
+
+var (
+	// ErrIllegalTransition is returned for status changes the incident lifecycle does not allow
+	ErrIllegalTransition = errors.New("illegal incident status transition")
+	// ErrNotResolved is returned when reopening an incident that is still open
+	ErrNotResolved = errors.New("only resolved incidents can be reopened")
+)
+
+// CanTransition reports whether an incident may move from one status to
+// another. Incidents only move forward, possibly skipping steps; a resolved
+// incident has to be reopened explicitly.
+func CanTransition(from string, to string) bool {
+	fromRank, ok := incidentStatusOrder[from]
+	if !ok {
+		return false
+	}
+	toRank, ok := incidentStatusOrder[to]
+	if !ok {
+		return false
+	}
+	return toRank > fromRank
+}
+
+// PrepareIncident stamps the lifecycle times of a new incident for its initial
+// status and attaches the opening update, so both are saved with the incident
+func PrepareIncident(incident *models.Incident, message string, now time.Time) {
+	stampIncident(incident, incident.Status, now)
+
+	if message == "" {
+		message = incidentStatusMessages[incident.Status]
+	}
+	incident.Updates = append(incident.Updates, models.IncidentUpdate{
+		Message:   message,
+		Status:    incident.Status,
+		Timestamp: now,
+	})
+}
+
+// TransitionIncident moves an open incident forward to a new status, stamps
+// its lifecycle times and records the change as an incident update. An empty
+// message is replaced by a generic one for the new status.
+func TransitionIncident(tx *gorm.DB, incident *models.Incident, to string, message string, now time.Time) (models.IncidentUpdate, error) {
+	if !CanTransition(incident.Status, to) {
+		return models.IncidentUpdate{}, fmt.Errorf("%w: %s to %s", ErrIllegalTransition, incident.Status, to)
+	}
+
+	return applyTransition(tx, incident, to, message, now)
+}
+
+// ReopenIncident moves a resolved incident back to investigating
+func ReopenIncident(tx *gorm.DB, incident *models.Incident, message string, now time.Time) (models.IncidentUpdate, error) {
+	if incident.Status != models.IncidentStatusResolved {
+		return models.IncidentUpdate{}, ErrNotResolved
+	}
+
+	if message == "" {
+		message = "This incident has been reopened and is being investigated."
+	}
+	return applyTransition(tx, incident, models.IncidentStatusInvestigating, message, now)
+}
+
+func applyTransition(tx *gorm.DB, incident *models.Incident, to string, message string, now time.Time) (models.IncidentUpdate, error) {
+	stampIncident(incident, to, now)
+	incident.Status = to
+
+	if err := tx.Model(incident).Updates(map[string]interface{}{
+		"status":        incident.Status,
+		"identified_at": incident.IdentifiedAt,
+		"resolved_at":   incident.ResolvedAt,
+	}).Error; err != nil {
+		return models.IncidentUpdate{}, err
+	}
+
+	if message == "" {
+		message = incidentStatusMessages[to]
+	}
+	update := models.IncidentUpdate{
+		Message:    message,
+		Status:     to,
+		Timestamp:  now,
+		IncidentID: fmt.Sprint(incident.ID),
+	}
+	if err := tx.Create(&update).Error; err != nil {
+		return update, err
+	}
+
+	return update, nil
+}
+
+// stampIncident sets the lifecycle times for an incident entering the given status
+func stampIncident(incident *models.Incident, status string, now time.Time) {
+	switch status {
+	case models.IncidentStatusIdentified:
+		// Keep the first identification when an incident is reopened
+		if incident.IdentifiedAt == nil {
+			incident.IdentifiedAt = &now
+		}
+	case models.IncidentStatusResolved:
+		incident.ResolvedAt = &now
+	case models.IncidentStatusInvestigating:
+		incident.ResolvedAt = nil
+	}
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CreateIncidentUpdateRequest struct {
+	Message string `json:"message" validate:"required"`
+	Status  string `json:"status" validate:"omitempty,oneof=investigating identified monitoring resolved"` // Defaults to the incident's current status
+}
+
+type ReopenIncidentRequest struct {
+	Message string `json:"message"`
+}
+
+type EditIncidentUpdateRequest struct {
+	Message string `json:"message" validate:"required"`
+}
+
+// CreateIncidentUpdate appends an update to an incident and moves the incident
+// to the status the update carries
+func CreateIncidentUpdate(c *fiber.Ctx) error {
+	incidentID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if incidentID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Incident ID and Organization ID are required",
+		})
+	}
+
+	var req CreateIncidentUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	org, incident, err := findIncident(database, clerkOrgID, incidentID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	update, err := AddIncidentUpdate(database, org, &incident, req.Status, req.Message, RequestActor(c))
+	if errors.Is(err, ErrIllegalTransition) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Cannot move incident from " + incident.Status + " to " + req.Status,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create incident update",
+		})
+	}
+
+	return c.Status(201).JSON(update)
+}
+
+// AddIncidentUpdate appends an update to an incident of the organization,
+// moving the incident to the given status unless it is empty or unchanged,
+// and announces it
+func AddIncidentUpdate(database *gorm.DB, org models.Organization, incident *models.Incident, status string, message string, actorUserID string) (models.IncidentUpdate, error) {
+	var update models.IncidentUpdate
+	var changeStatus bool
+
+	// Start a transaction so the update and the incident status stay in sync
+	err := database.Transaction(func(tx *gorm.DB) error {
+		// Read the incident again under a row lock, so that concurrent
+		// updates are checked against the status the other one left
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(incident, incident.ID).Error; err != nil {
+			return err
+		}
+
+		// An update that changes the status is the record of that transition
+		changeStatus = status != "" && status != incident.Status
+		if changeStatus && !CanTransition(incident.Status, status) {
+			return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, incident.Status, status)
+		}
+
+		if changeStatus {
+			var err error
+			update, err = TransitionIncident(tx, incident, status, message, time.Now())
+			return err
+		}
+
+		update = models.IncidentUpdate{
+			Message:    message,
+			Status:     incident.Status,
+			Timestamp:  time.Now(),
+			IncidentID: fmt.Sprint(incident.ID),
+		}
+		return tx.Create(&update).Error
+	})
+	if err != nil {
+		return models.IncidentUpdate{}, err
+	}
+
+	if changeStatus {
+		refreshServiceStatuses(database, incidentServiceIDs(database, incident.ID), StatusCause{
+			Source:      models.StatusSourceIncident,
+			ActorUserID: actorUserID,
+			Reason:      fmt.Sprintf("Incident %d %s: %s", incident.ID, incident.Status, message),
+		})
+	}
+	publishIncident(database, org.Slug, "updated", incident.ID)
+
+	return update, nil
+}
+
+// ListIncidentUpdates lists the updates of an incident, oldest first
+func ListIncidentUpdates(c *fiber.Ctx) error {
+	incidentID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if incidentID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Incident ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	if _, _, err := findIncident(database, clerkOrgID, incidentID); err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var updates []models.IncidentUpdate
+	if err := database.Where("incident_id = ?", incidentID).
+		Order("timestamp ASC").
+		Find(&updates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch incident updates",
+		})
+	}
+
+	return c.Status(200).JSON(updates)
+}
+
+// EditIncidentUpdate corrects the message of an incident update. Its status
+// and timestamp are history and stay as posted.
+func EditIncidentUpdate(c *fiber.Ctx) error {
+	incidentID := c.Params("id")
+	updateID := c.Params("updateId")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if incidentID == "" || updateID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Incident ID, Update ID and Organization ID are required",
+		})
+	}
+
+	var req EditIncidentUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	org, incident, err := findIncident(database, clerkOrgID, incidentID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var update models.IncidentUpdate
+	if err := database.Where("id = ? AND incident_id = ?", updateID, incidentID).First(&update).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Incident update not found or does not belong to the incident",
+		})
+	}
+
+	update.Message = req.Message
+	if err := database.Save(&update).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update incident update",
+		})
+	}
+
+	publishIncident(database, org.Slug, "updated", incident.ID)
+
+	return c.Status(200).JSON(update)
+}
+
+// HandleReopenIncident moves a resolved incident back to investigating
+func HandleReopenIncident(c *fiber.Ctx) error {
+	incidentID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if incidentID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Incident ID and Organization ID are required",
+		})
+	}
+
+	var req ReopenIncidentRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	database := db.GetDB()
+
+	org, incident, err := findIncident(database, clerkOrgID, incidentID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if incident.Status != models.IncidentStatusResolved {
+		return c.Status(409).JSON(fiber.Map{
+			"error": ErrNotResolved.Error(),
+		})
+	}
+
+	tx := database.Begin()
+
+	if _, err := ReopenIncident(tx, &incident, req.Message, time.Now()); err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to reopen incident",
+		})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	refreshServiceStatuses(database, incidentServiceIDs(database, incident.ID), StatusCause{
+		Source:      models.StatusSourceIncident,
+		ActorUserID: RequestActor(c),
+		Reason:      fmt.Sprintf("Incident %d reopened: %s", incident.ID, incident.Title),
+	})
+	publishIncident(database, org.Slug, "reopened", incident.ID)
+
+	incident, err = loadIncident(database, incident.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch incident",
+		})
+	}
+
+	return c.Status(200).JSON(incident)
+}
+
+// findIncident loads an incident of the organization identified by its Clerk ID
+func findIncident(database *gorm.DB, clerkOrgID string, incidentID string) (models.Organization, models.Incident, error) {
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return org, models.Incident{}, errors.New("Organization not found")
+	}
+
+	var incident models.Incident
+	if err := database.Where("id = ? AND organization_id = ?", incidentID, org.ID).First(&incident).Error; err != nil {
+		return org, incident, errors.New("Incident not found or does not belong to the organization")
+	}
+
+	return org, incident, nil
+}
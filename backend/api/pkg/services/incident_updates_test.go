@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAddIncidentUpdateChecksTheStoredStatus(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := database.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := database.AutoMigrate(&models.Organization{}, &models.Incident{}, &models.IncidentService{}, &models.IncidentUpdate{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	org := models.Organization{ID: "org", ClerkOrgID: "org_1", Name: "Acme", Slug: "acme"}
+	database.Create(&org)
+	incident := models.Incident{Title: "Database outage", Status: models.IncidentStatusInvestigating, OrganizationID: org.ID}
+	database.Create(&incident)
+
+	// Another request resolved the incident after this copy was read
+	stale := incident
+	database.Model(&incident).Update("status", models.IncidentStatusResolved)
+
+	_, err = AddIncidentUpdate(database, org, &stale, models.IncidentStatusIdentified, "Found the cause", "user_1")
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("moving a resolved incident back: err = %v", err)
+	}
+	if stale.Status != models.IncidentStatusResolved {
+		t.Errorf("the incident was checked as %s", stale.Status)
+	}
+
+	var updates int64
+	database.Model(&models.IncidentUpdate{}).Count(&updates)
+	if updates != 0 {
+		t.Errorf("%d updates posted for an illegal transition", updates)
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateMaintenanceRequest struct {
+	Title          string    `json:"title" validate:"required"`
+	Description    string    `json:"description"`
+	ScheduledStart time.Time `json:"scheduled_start" validate:"required"`
+	ScheduledEnd   time.Time `json:"scheduled_end" validate:"required,gtfield=ScheduledStart"`
+	ServiceID      string    `json:"service_id" validate:"required"`
+	OrganizationID string    `json:"organization_id" validate:"required"`
+}
+
+type UpdateMaintenanceRequest struct {
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	ScheduledStart *time.Time `json:"scheduled_start"`
+	ScheduledEnd   *time.Time `json:"scheduled_end"`
+}
+
+// CreateMaintenance schedules a maintenance window for a service of the organization
+func CreateMaintenance(c *fiber.Ctx) error {
+	var req CreateMaintenanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// The organization always comes from the session
+	req.OrganizationID = auth.OrganizationID(c)
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", req.OrganizationID).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var service models.Service
+	if err := database.Where("id = ? AND organization_id = ?", req.ServiceID, org.ID).First(&service).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Service not found or does not belong to the organization",
+		})
+	}
+
+	maintenance := models.Maintenance{
+		Title:          req.Title,
+		Description:    req.Description,
+		ScheduledStart: req.ScheduledStart,
+		ScheduledEnd:   req.ScheduledEnd,
+		Status:         "scheduled",
+		ServiceID:      req.ServiceID,
+		OrganizationID: org.ID,
+	}
+
+	if err := database.Create(&maintenance).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create maintenance",
+		})
+	}
+
+	events.PublishMaintenance(org.Slug, "created", maintenance)
+
+	return c.Status(fiber.StatusCreated).JSON(maintenance)
+}
+
+// ListMaintenances lists the maintenances of an organization, optionally
+// filtered by service and status
+func ListMaintenances(c *fiber.Ctx) error {
+	clerkOrgID := auth.OrganizationID(c)
+	serviceID := c.Query("service_id")
+	status := c.Query("status")
+
+	if clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Organization ID is required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	query := database.Where("organization_id = ?", org.ID)
+	if serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var maintenances []models.Maintenance
+	if err := query.Order("scheduled_start ASC").Find(&maintenances).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch maintenances",
+		})
+	}
+
+	return c.Status(200).JSON(maintenances)
+}
+
+// UpdateMaintenance updates a maintenance that has not completed yet
+func UpdateMaintenance(c *fiber.Ctx) error {
+	maintenanceID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if maintenanceID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Maintenance ID and Organization ID are required",
+		})
+	}
+
+	var req UpdateMaintenanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var maintenance models.Maintenance
+	if err := database.Where("id = ? AND organization_id = ?", maintenanceID, org.ID).First(&maintenance).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Maintenance not found or does not belong to the organization",
+		})
+	}
+
+	if maintenance.Status == "completed" || maintenance.Status == "cancelled" {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Maintenance is already " + maintenance.Status,
+		})
+	}
+
+	// Update the maintenance fields if they are provided
+	if req.Title != "" {
+		maintenance.Title = req.Title
+	}
+	if req.Description != "" {
+		maintenance.Description = req.Description
+	}
+	if req.ScheduledStart != nil {
+		maintenance.ScheduledStart = *req.ScheduledStart
+	}
+	if req.ScheduledEnd != nil {
+		maintenance.ScheduledEnd = *req.ScheduledEnd
+	}
+
+	if !maintenance.ScheduledEnd.After(maintenance.ScheduledStart) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Scheduled end must be after scheduled start",
+		})
+	}
+
+	if err := database.Save(&maintenance).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update maintenance",
+		})
+	}
+
+	events.PublishMaintenance(org.Slug, "updated", maintenance)
+
+	return c.Status(200).JSON(maintenance)
+}
+
+// CancelMaintenance cancels a maintenance that has not completed yet
+func CancelMaintenance(c *fiber.Ctx) error {
+	maintenanceID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if maintenanceID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Maintenance ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var maintenance models.Maintenance
+	if err := database.Where("id = ? AND organization_id = ?", maintenanceID, org.ID).First(&maintenance).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Maintenance not found or does not belong to the organization",
+		})
+	}
+
+	if maintenance.Status == "completed" || maintenance.Status == "cancelled" {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Maintenance is already " + maintenance.Status,
+		})
+	}
+
+	// Cancelling an in progress maintenance restores the service status right away
+	if err := endMaintenance(database, &maintenance, "cancelled", RequestActor(c), time.Now()); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to cancel maintenance",
+		})
+	}
+
+	return c.Status(200).JSON(maintenance)
+}
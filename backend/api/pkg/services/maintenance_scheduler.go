@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// maintenanceTickInterval is how often maintenances are checked for due transitions
+const maintenanceTickInterval = 30 * time.Second
+
+// MaintenanceScheduler starts and completes maintenances at their scheduled
+// times and puts their services under maintenance meanwhile
+type MaintenanceScheduler struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceScheduler(database *gorm.DB) *MaintenanceScheduler {
+	return &MaintenanceScheduler{db: database}
+}
+
+// Start runs the scheduler in the background until the context is cancelled
+func (s *MaintenanceScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(maintenanceTickInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.Run(time.Now()); err != nil {
+				log.Printf("maintenance: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Run applies every transition that is due at now
+func (s *MaintenanceScheduler) Run(now time.Time) error {
+	// Complete first so back-to-back maintenances hand the service over cleanly
+	var ending []models.Maintenance
+	if err := s.db.Where("status IN ? AND scheduled_end <= ?", []string{"scheduled", "in_progress"}, now).
+		Find(&ending).Error; err != nil {
+		return err
+	}
+	for _, maintenance := range ending {
+		if err := endMaintenance(s.db, &maintenance, "completed", "", now); err != nil {
+			log.Printf("maintenance: failed to complete maintenance %d: %v", maintenance.ID, err)
+		}
+	}
+
+	var starting []models.Maintenance
+	if err := s.db.Where("status = ? AND scheduled_start <= ? AND scheduled_end > ?", "scheduled", now, now).
+		Order("scheduled_start ASC").
+		Find(&starting).Error; err != nil {
+		return err
+	}
+	for _, maintenance := range starting {
+		if err := startMaintenance(s.db, &maintenance, now); err != nil {
+			log.Printf("maintenance: failed to start maintenance %d: %v", maintenance.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// startMaintenance moves the maintenance to in_progress and the service under maintenance
+func startMaintenance(database *gorm.DB, maintenance *models.Maintenance, now time.Time) error {
+	var service models.Service
+	if err := database.Where("id = ?", maintenance.ServiceID).First(&service).Error; err != nil {
+		return err
+	}
+
+	previousStatus := service.Status
+
+	// Overlapping maintenances restore whatever the service had before the first one
+	if service.Status == models.ServiceStatusUnderMaintenance {
+		var active models.Maintenance
+		if err := database.Where("service_id = ? AND status = ? AND id <> ?", maintenance.ServiceID, "in_progress", maintenance.ID).
+			First(&active).Error; err == nil {
+			previousStatus = active.PreviousServiceStatus
+		} else {
+			previousStatus = models.ServiceStatusOperational
+		}
+	}
+
+	maintenance.Status = "in_progress"
+	maintenance.StartedAt = &now
+	maintenance.PreviousServiceStatus = previousStatus
+	if err := database.Save(maintenance).Error; err != nil {
+		return err
+	}
+
+	cause := maintenanceCause(*maintenance, "started", "")
+	if err := applyMaintenanceStatus(database, &service, models.ServiceStatusUnderMaintenance, cause); err != nil {
+		return err
+	}
+
+	return publishMaintenance(database, "started", *maintenance)
+}
+
+// endMaintenance moves the maintenance to completed or cancelled and, unless
+// another maintenance of the service is still in progress, restores the
+// service status it had before the maintenance started. The actor is empty
+// when the maintenance ends on schedule.
+func endMaintenance(database *gorm.DB, maintenance *models.Maintenance, status string, actorUserID string, now time.Time) error {
+	wasInProgress := maintenance.Status == "in_progress"
+
+	maintenance.Status = status
+	if status == "completed" {
+		maintenance.CompletedAt = &now
+	}
+	if err := database.Save(maintenance).Error; err != nil {
+		return err
+	}
+
+	if wasInProgress {
+		var stillActive int64
+		if err := database.Model(&models.Maintenance{}).
+			Where("service_id = ? AND status = ?", maintenance.ServiceID, "in_progress").
+			Count(&stillActive).Error; err != nil {
+			return err
+		}
+
+		var service models.Service
+		if err := database.Where("id = ?", maintenance.ServiceID).First(&service).Error; err != nil {
+			return err
+		}
+
+		// Leave the service alone if an operator already changed its status
+		serviceStatus := service.Status
+		if stillActive == 0 && service.Status == models.ServiceStatusUnderMaintenance {
+			serviceStatus = maintenance.PreviousServiceStatus
+			if serviceStatus == "" {
+				serviceStatus = models.ServiceStatusOperational
+			}
+		}
+		cause := maintenanceCause(*maintenance, status, actorUserID)
+		if err := applyMaintenanceStatus(database, &service, serviceStatus, cause); err != nil {
+			return err
+		}
+	}
+
+	return publishMaintenance(database, status, *maintenance)
+}
+
+// applyMaintenanceStatus sets the service status a maintenance calls for and
+// refreshes its computed status. Organizations that derive statuses only get
+// the refresh, which already accounts for active maintenances.
+func applyMaintenanceStatus(database *gorm.DB, service *models.Service, status string, cause StatusCause) error {
+	var org models.Organization
+	if err := database.Where("id = ?", service.OrganizationID).First(&org).Error; err != nil {
+		return err
+	}
+
+	if org.StatusMode != models.StatusModeDerived {
+		if err := SetServiceStatus(database, service, status, cause); err != nil {
+			return err
+		}
+	}
+
+	return RefreshServiceStatus(database, fmt.Sprint(service.ID), cause)
+}
+
+func maintenanceCause(maintenance models.Maintenance, action string, actorUserID string) StatusCause {
+	return StatusCause{
+		Source:      models.StatusSourceMaintenance,
+		ActorUserID: actorUserID,
+		Reason:      fmt.Sprintf("Maintenance %d %s: %s", maintenance.ID, action, maintenance.Title),
+	}
+}
+
+func publishMaintenance(database *gorm.DB, action string, maintenance models.Maintenance) error {
+	var org models.Organization
+	if err := database.Where("id = ?", maintenance.OrganizationID).First(&org).Error; err != nil {
+		return err
+	}
+
+	events.PublishMaintenance(org.Slug, action, maintenance)
+	return nil
+}
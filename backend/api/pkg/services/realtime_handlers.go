@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const pingInterval = 30 * time.Second
+
+// sseKeepAliveInterval keeps idle proxies from closing the event stream
+const sseKeepAliveInterval = 15 * time.Second
+
+// RequireWebSocketUpgrade rejects plain HTTP requests and unknown organizations
+// before the connection is upgraded
+func RequireWebSocketUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	var org models.Organization
+	if err := db.GetDB().Where("slug = ?", c.Params("slug")).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	return c.Next()
+}
+
+// StreamOrganizationEvents pushes every event of the organization to the websocket client
+var StreamOrganizationEvents = websocket.New(func(conn *websocket.Conn) {
+	sub := events.Subscribe(conn.Params("slug"))
+	defer events.Unsubscribe(sub)
+
+	// The client never sends anything we care about, but reading is the only
+	// way to notice that it went away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+})
+
+// StreamOrganizationStatus streams the organization's events as Server-Sent Events.
+// New clients first receive a "status" event with the same payload as
+// GetOrganizationStatus. Clients reconnecting with a Last-Event-ID header get the
+// events they missed replayed instead, or a fresh snapshot if those are gone.
+func StreamOrganizationStatus(c *fiber.Ctx) error {
+	orgSlug := c.Params("slug")
+	if orgSlug == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Organization slug is required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("slug = ?", orgSlug).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	return streamEvents(c, org.Slug, func() (fiber.Map, error) {
+		return buildOrganizationStatus(database, org)
+	})
+}
+
+// streamEvents streams the events of the organization slug, starting with the
+// snapshot returned by status unless the client can resume where it left off
+func streamEvents(c *fiber.Ctx, slug string, status func() (fiber.Map, error)) error {
+	// EventSource sends the header on reconnect, the query parameter lets
+	// clients resume after a full page reload
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+
+	var (
+		sub    *events.Subscriber
+		missed []events.Event
+		resume bool
+	)
+	if lastEventID != "" {
+		sub, missed, resume = events.Resume(slug, lastEventID)
+	} else {
+		sub = events.Subscribe(slug)
+	}
+
+	var snapshot fiber.Map
+	var snapshotID uint64
+	if !resume {
+		// Take the ID before the snapshot so no event committed meanwhile is lost
+		snapshotID = events.LastID()
+		var err error
+		snapshot, err = status()
+		if err != nil {
+			events.Unsubscribe(sub)
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer events.Unsubscribe(sub)
+
+		if snapshot != nil {
+			if err := writeSSE(w, events.EventID(snapshotID), "status", snapshot); err != nil {
+				return
+			}
+		}
+		for _, event := range missed {
+			if err := writeSSE(w, events.EventID(event.ID), event.Type, event); err != nil {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(sseKeepAliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				if err := writeSSE(w, events.EventID(event.ID), event.Type, event); err != nil {
+					return
+				}
+			case <-ticker.C:
+				// Comment lines are ignored by clients; a failed flush means the client is gone
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeSSE writes a single Server-Sent Event and flushes it to the client
+func writeSSE(w *bufio.Writer, id string, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, eventType, payload); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
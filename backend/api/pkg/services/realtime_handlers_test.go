@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sseEvent is an event read back from the stream
+type sseEvent struct {
+	ID   string
+	Type string
+}
+
+func TestStreamEventsResume(t *testing.T) {
+	const slug = "resume-test"
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/events", func(c *fiber.Ctx) error {
+		return streamEvents(c, slug, func() (fiber.Map, error) {
+			return fiber.Map{"services": []string{}}, nil
+		})
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	defer app.ShutdownWithTimeout(time.Second)
+
+	url := "http://" + ln.Addr().String() + "/events"
+
+	// A new client starts from a snapshot and then gets live events
+	stream := openStream(t, url, "")
+	snapshot := stream.next(t)
+	if snapshot.Type != "status" {
+		t.Fatalf("first event = %q, want the status snapshot", snapshot.Type)
+	}
+
+	events.PublishStatusChange(slug, models.Service{Status: models.ServiceStatusDegraded})
+	events.PublishStatusChange(slug, models.Service{Status: models.ServiceStatusOperational})
+	stream.next(t)
+	seen := stream.next(t)
+	stream.close()
+
+	// Events published while the client is away are replayed on reconnect
+	events.PublishStatusChange(slug, models.Service{Status: models.ServiceStatusMajorOutage})
+	events.PublishIncident(slug, "created", models.Incident{Title: "Outage"})
+
+	stream = openStream(t, url, seen.ID)
+	first := stream.next(t)
+	second := stream.next(t)
+	stream.close()
+
+	if first.Type != events.TypeStatusChange || second.Type != events.TypeIncidentUpdate {
+		t.Fatalf("replayed %q and %q, want the missed status change and incident", first.Type, second.Type)
+	}
+	if first.ID == seen.ID || second.ID == first.ID {
+		t.Fatalf("replayed IDs %q and %q should follow %q", first.ID, second.ID, seen.ID)
+	}
+
+	// IDs handed out before a restart cannot be resumed from
+	for _, lastEventID := range []string{"500", "0-" + strings.SplitN(seen.ID, "-", 2)[1], "garbage"} {
+		stream = openStream(t, url, lastEventID)
+		if event := stream.next(t); event.Type != "status" {
+			t.Errorf("resuming from %q started with %q, want the status snapshot", lastEventID, event.Type)
+		}
+		stream.close()
+	}
+}
+
+// eventStream reads Server-Sent Events from a response
+type eventStream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+func openStream(t *testing.T, url string, lastEventID string) *eventStream {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	return &eventStream{resp: resp, reader: bufio.NewReader(resp.Body)}
+}
+
+// next returns the next event of the stream, skipping keep-alive comments
+func (s *eventStream) next(t *testing.T) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && event.Type != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Type = strings.TrimPrefix(line, "event: ")
+		}
+	}
+}
+
+func (s *eventStream) close() {
+	s.resp.Body.Close()
+}
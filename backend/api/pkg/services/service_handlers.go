@@ -2,7 +2,10 @@ package services
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
 	"github.com/apsinghdev/PopenStatus/api/pkg/db"
 	"github.com/apsinghdev/PopenStatus/api/pkg/models"
 	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
@@ -26,6 +29,10 @@ func HandleCreateService(c *fiber.Ctx) error {
 		})
 	}
 
+	// The organization and the creator always come from the session
+	req.OrganizationID = auth.OrganizationID(c)
+	req.UserID = auth.UserID(c)
+
 	// Validate request
 	if err := utils.Validate.Struct(req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -55,12 +62,134 @@ func HandleCreateService(c *fiber.Ctx) error {
 		OrganizationID: organization.ID,
 	}
 
-	// Save to database
-	if err := database.Create(&service).Error; err != nil {
+	// Save to database along with the initial status, which starts the uptime history
+	tx := database.Begin()
+
+	if err := tx.Create(&service).Error; err != nil {
+		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create service",
 		})
 	}
 
+	cause := StatusCause{Source: models.StatusSourceManual, ActorUserID: req.UserID, Reason: "Service created"}
+	if err := recordStatusChange(tx, service, "", cause); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record service status",
+		})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(service)
 }
+
+// ClearServiceOverride removes the manual status override of a service, which
+// goes back to its computed status
+func ClearServiceOverride(c *fiber.Ctx) error {
+	serviceID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if serviceID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Service ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var service models.Service
+	if err := database.Where("id = ? AND organization_id = ?", serviceID, org.ID).First(&service).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Service not found or does not belong to the organization",
+		})
+	}
+
+	cause := StatusCause{Source: models.StatusSourceManual, ActorUserID: RequestActor(c), Reason: "Manual override cleared"}
+	if err := ClearOverride(database, &service, cause); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to clear status override",
+		})
+	}
+
+	if err := database.First(&service, service.ID).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch service",
+		})
+	}
+
+	return c.Status(200).JSON(service)
+}
+
+// GetServiceHistory returns the status changes of a service, most recent
+// first, optionally limited to the from/to time range (RFC 3339)
+func GetServiceHistory(c *fiber.Ctx) error {
+	serviceID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if serviceID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Service ID and Organization ID are required",
+		})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	// The history of deleted services stays available
+	var service models.Service
+	if err := database.Unscoped().Where("id = ? AND organization_id = ?", serviceID, org.ID).First(&service).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Service not found or does not belong to the organization",
+		})
+	}
+
+	query := database.Where("service_id = ?", serviceID)
+	for param, condition := range map[string]string{"from": "changed_at >= ?", "to": "changed_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": param + " must be an RFC 3339 timestamp",
+			})
+		}
+		query = query.Where(condition, t)
+	}
+
+	var changes []models.ServiceStatusChange
+	if err := query.Order("changed_at DESC").Limit(limit).Find(&changes).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch service history",
+		})
+	}
+
+	return c.Status(200).JSON(changes)
+}
@@ -1,10 +1,18 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
 	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
 	"github.com/apsinghdev/PopenStatus/api/pkg/models"
 	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // func to create a service
@@ -26,7 +34,7 @@ func ListServices(c *fiber.Ctx) error {
 	db := db.Connect()
 
 	// Get organization ID from query parameters
-	clerkOrgID := c.Query("organization_id")
+	clerkOrgID := auth.OrganizationID(c)
 	if clerkOrgID == "" {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Organization ID is required",
@@ -53,11 +61,12 @@ func ListServices(c *fiber.Ctx) error {
 }
 
 type CreateIncidentRequest struct {
-	Title          string `json:"title" validate:"required"`
-	Description    string `json:"description"`
-	Status         string `json:"status" validate:"required,oneof=investigating identified resolved"`
-	ServiceID      string `json:"service_id" validate:"required"`
-	OrganizationID string `json:"organization_id" validate:"required"`
+	Title          string            `json:"title" validate:"required"`
+	Description    string            `json:"description"`
+	Status         string            `json:"status" validate:"required,oneof=investigating identified monitoring resolved"`
+	Services       []AffectedService `json:"services" validate:"required_without=ServiceID,dive"`
+	ServiceID      string            `json:"service_id"` // Shorthand for a single affected service with the default impact
+	OrganizationID string            `json:"organization_id" validate:"required"`
 }
 
 // func to create an incident
@@ -70,6 +79,9 @@ func CreateIncident(c *fiber.Ctx) error {
 		})
 	}
 
+	// The organization always comes from the session
+	req.OrganizationID = auth.OrganizationID(c)
+
 	// Validate request
 	if err := utils.Validate.Struct(req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -89,17 +101,23 @@ func CreateIncident(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create incident
-	incident := models.Incident{
-		Title:          req.Title,
-		Description:    req.Description,
-		Status:         req.Status,
-		ServiceID:      req.ServiceID,
-		OrganizationID: organization.ID,
+	affected := req.Services
+	if len(affected) == 0 {
+		affected = []AffectedService{{ServiceID: req.ServiceID}}
 	}
 
-	// Save to database
-	if err := database.Create(&incident).Error; err != nil {
+	incident := models.Incident{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      req.Status,
+	}
+	if err := OpenIncident(database, organization, &incident, affected, RequestActor(c)); err != nil {
+		var inputErr *InputError
+		if errors.As(err, &inputErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create incident",
 		})
@@ -108,13 +126,42 @@ func CreateIncident(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(incident)
 }
 
+// OpenIncident creates an incident of the organization affecting the given
+// services, refreshes their statuses and announces it. The description is
+// used as the opening update.
+func OpenIncident(database *gorm.DB, org models.Organization, incident *models.Incident, affected []AffectedService, actorUserID string) error {
+	links, err := incidentServices(database, org, affected)
+	if err != nil {
+		return &InputError{Message: err.Error()}
+	}
+
+	// Create incident along with its affected services
+	incident.OrganizationID = org.ID
+	incident.Services = links
+	PrepareIncident(incident, incident.Description, time.Now())
+
+	// Save to database
+	if err := database.Create(incident).Error; err != nil {
+		return err
+	}
+
+	refreshServiceStatuses(database, incidentServiceIDs(database, incident.ID), StatusCause{
+		Source:      models.StatusSourceIncident,
+		ActorUserID: actorUserID,
+		Reason:      fmt.Sprintf("Incident %d created: %s", incident.ID, incident.Title),
+	})
+	publishIncident(database, org.Slug, "created", incident.ID)
+
+	return nil
+}
+
 // func to list incidents
 func ListIncidents(c *fiber.Ctx) error {
 	var incidents []models.Incident
 	db := db.Connect()
 
 	// Get organization ID and service ID from query parameters
-	clerkOrgID := c.Query("organization_id")
+	clerkOrgID := auth.OrganizationID(c)
 	serviceID := c.Query("service_id")
 
 	if clerkOrgID == "" {
@@ -134,13 +181,13 @@ func ListIncidents(c *fiber.Ctx) error {
 	// Build the query
 	query := db.Where("organization_id = ?", org.ID)
 
-	// Add service ID filter if provided
+	// Add service ID filter if provided, matching incidents affecting that service
 	if serviceID != "" {
-		query = query.Where("service_id = ?", serviceID)
+		query = query.Where("id IN (SELECT incident_id FROM incident_services WHERE service_id = ?)", serviceID)
 	}
 
 	// Execute the query
-	result := query.Find(&incidents)
+	result := query.Preload("Services.Service").Find(&incidents)
 	if result.Error != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to fetch incidents",
@@ -169,23 +216,43 @@ func GetOrganizationStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	response, err := buildOrganizationStatus(db, org)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(200).JSON(response)
+}
+
+// buildOrganizationStatus assembles the public status payload of an organization
+func buildOrganizationStatus(db *gorm.DB, org models.Organization) (fiber.Map, error) {
 	// Fetch all services for the organization
 	var services []models.Service
 	if err := db.Where("organization_id = ?", org.ID).Find(&services).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch services",
-		})
+		return nil, errors.New("Failed to fetch services")
 	}
 
 	// Fetch all incidents for the organization
 	var incidents []models.Incident
 	if err := db.Where("organization_id = ?", org.ID).
-		Preload("Updates").
-		Preload("Service").
+		Preload("Updates", func(db *gorm.DB) *gorm.DB {
+			return db.Order("timestamp ASC")
+		}).
+		Preload("Services.Service").
 		Find(&incidents).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch incidents",
-		})
+		return nil, errors.New("Failed to fetch incidents")
+	}
+
+	// Fetch the upcoming and active maintenances for the organization
+	var maintenances []models.Maintenance
+	if err := db.Where("organization_id = ? AND status IN ? AND scheduled_end > ?",
+		org.ID, []string{"scheduled", "in_progress"}, time.Now()).
+		Preload("Service").
+		Order("scheduled_start ASC").
+		Find(&maintenances).Error; err != nil {
+		return nil, errors.New("Failed to fetch maintenances")
 	}
 
 	// Prepare the response
@@ -195,17 +262,19 @@ func GetOrganizationStatus(c *fiber.Ctx) error {
 			"name": org.Name,
 			"slug": org.Slug,
 		},
-		"services":  services,
-		"incidents": incidents,
+		"status_mode":  org.StatusMode,
+		"services":     services,
+		"incidents":    incidents,
+		"maintenances": maintenances,
 	}
 
-	return c.Status(200).JSON(response)
+	return response, nil
 }
 
-// DeleteService deletes a service and all its related data
+// DeleteService deletes a service and its related data, except for its status history
 func DeleteService(c *fiber.Ctx) error {
 	serviceID := c.Params("id")
-	clerkOrgID := c.Query("organization_id")
+	clerkOrgID := auth.OrganizationID(c)
 
 	if serviceID == "" || clerkOrgID == "" {
 		return c.Status(400).JSON(fiber.Map{
@@ -234,22 +303,88 @@ func DeleteService(c *fiber.Ctx) error {
 	// Start a transaction to ensure all deletions are atomic
 	tx := db.Begin()
 
-	// Delete all incident updates for incidents related to this service
-	if err := tx.Where("incident_id IN (SELECT id FROM incidents WHERE service_id = ?)", serviceID).Delete(&models.IncidentUpdate{}).Error; err != nil {
+	// Unlink the incidents affecting this service
+	var incidentIDs []uint
+	if err := tx.Model(&models.IncidentService{}).Where("service_id = ?", serviceID).Pluck("incident_id", &incidentIDs).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch incidents",
+		})
+	}
+
+	if err := tx.Where("service_id = ?", serviceID).Delete(&models.IncidentService{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete incident services",
+		})
+	}
+
+	// Incidents that affected no other service go away with it
+	var orphanIDs []uint
+	if err := tx.Model(&models.Incident{}).
+		Where("id IN ? AND id NOT IN (SELECT incident_id FROM incident_services)", incidentIDs).
+		Pluck("id", &orphanIDs).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch incidents",
+		})
+	}
+
+	// Delete all incident updates for those incidents
+	if err := tx.Where("incident_id IN ?", idStrings(orphanIDs)).Delete(&models.IncidentUpdate{}).Error; err != nil {
 		tx.Rollback()
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete incident updates",
 		})
 	}
 
-	// Delete all incidents related to this service
-	if err := tx.Where("service_id = ?", serviceID).Delete(&models.Incident{}).Error; err != nil {
+	// Delete the incidents themselves
+	if err := tx.Where("id IN ?", orphanIDs).Delete(&models.Incident{}).Error; err != nil {
 		tx.Rollback()
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete incidents",
 		})
 	}
 
+	// Delete the uptime rollups of this service. Its status history is kept
+	// for postmortems.
+	if err := tx.Where("service_id = ?", serviceID).Delete(&models.ServiceUptimeDaily{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete uptime history",
+		})
+	}
+
+	// Delete the SLOs and their alerts of this service
+	if err := tx.Where("slo_id IN (SELECT id FROM slos WHERE service_id = ?)", serviceID).Delete(&models.SLOAlert{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete SLO alerts",
+		})
+	}
+
+	if err := tx.Where("service_id = ?", serviceID).Delete(&models.SLO{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete SLOs",
+		})
+	}
+
+	// Delete all monitors and their check results related to this service
+	if err := tx.Where("monitor_id IN (SELECT id FROM monitors WHERE service_id = ?)", serviceID).Delete(&models.MonitorCheckResult{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete monitor check results",
+		})
+	}
+
+	if err := tx.Where("service_id = ?", serviceID).Delete(&models.Monitor{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete monitors",
+		})
+	}
+
 	// Delete all maintenance records related to this service
 	if err := tx.Where("service_id = ?", serviceID).Delete(&models.Maintenance{}).Error; err != nil {
 		tx.Rollback()
@@ -281,7 +416,7 @@ func DeleteService(c *fiber.Ctx) error {
 // UpdateService updates a service's information
 func UpdateService(c *fiber.Ctx) error {
 	serviceID := c.Params("id")
-	clerkOrgID := c.Query("organization_id")
+	clerkOrgID := auth.OrganizationID(c)
 
 	if serviceID == "" || clerkOrgID == "" {
 		return c.Status(400).JSON(fiber.Map{
@@ -291,9 +426,11 @@ func UpdateService(c *fiber.Ctx) error {
 
 	// Parse the update data
 	var updateData struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Status      string `json:"status"`
+		Name            string     `json:"name"`
+		Description     string     `json:"description"`
+		Status          string     `json:"status"`
+		StatusExpiresAt *time.Time `json:"status_expires_at"` // End of the manual override in derived mode
+		Reason          string     `json:"reason"`            // Why the status changed, kept in the service history
 	}
 	if err := c.BodyParser(&updateData); err != nil {
 		return c.Status(400).JSON(fiber.Map{
@@ -319,6 +456,8 @@ func UpdateService(c *fiber.Ctx) error {
 		})
 	}
 
+	previousStatus := service.Status
+
 	// Update the service fields if they are provided
 	if updateData.Name != "" {
 		service.Name = updateData.Name
@@ -327,11 +466,15 @@ func UpdateService(c *fiber.Ctx) error {
 		service.Description = updateData.Description
 	}
 	if updateData.Status != "" {
-		service.Status = updateData.Status
+		if err := overrideStatus(org, &service, updateData.Status, updateData.StatusExpiresAt); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 	}
 
-	// Save the updated service
-	if err := db.Save(&service).Error; err != nil {
+	cause := StatusCause{Source: models.StatusSourceManual, ActorUserID: RequestActor(c), Reason: updateData.Reason}
+	if err := saveService(db, org, &service, previousStatus, cause); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update service",
 		})
@@ -340,21 +483,68 @@ func UpdateService(c *fiber.Ctx) error {
 	return c.Status(200).JSON(service)
 }
 
+// ChangeServiceStatus sets the status of a service of the organization by
+// hand, saves it with its transition and announces it
+func ChangeServiceStatus(database *gorm.DB, org models.Organization, service *models.Service, status string, expiresAt *time.Time, cause StatusCause) error {
+	previousStatus := service.Status
+	if err := overrideStatus(org, service, status, expiresAt); err != nil {
+		return err
+	}
+	return saveService(database, org, service, previousStatus, cause)
+}
+
+// overrideStatus sets a status given by hand. Organizations deriving
+// statuses take it as a manual override that expires, an hour from now
+// unless expiresAt says otherwise.
+func overrideStatus(org models.Organization, service *models.Service, status string, expiresAt *time.Time) error {
+	if org.StatusMode == models.StatusModeDerived {
+		until := time.Now().Add(defaultOverrideDuration)
+		if expiresAt != nil {
+			until = *expiresAt
+		}
+		if !until.After(time.Now()) {
+			return &InputError{Message: "Status expiry must be in the future"}
+		}
+
+		service.ManualStatus = status
+		service.ManualStatusExpiresAt = &until
+	}
+	service.Status = status
+	return nil
+}
+
+// saveService saves the updated service together with its status transition
+// and announces it
+func saveService(database *gorm.DB, org models.Organization, service *models.Service, previousStatus string, cause StatusCause) error {
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(service).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, *service, previousStatus, cause)
+	})
+	if err != nil {
+		return err
+	}
+
+	events.PublishStatusChange(org.Slug, *service)
+	return nil
+}
+
 type UpdateIncidentRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Status      string `json:"status" validate:"omitempty,oneof=investigating identified resolved"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Status      string            `json:"status" validate:"omitempty,oneof=investigating identified monitoring resolved"`
+	Services    []AffectedService `json:"services" validate:"omitempty,dive"` // Replaces the affected services when provided
 }
 
 // DeleteIncident deletes an incident
 func DeleteIncident(c *fiber.Ctx) error {
 	incidentID := c.Params("id")
-	clerkOrgID := c.Query("organization_id")
-	serviceID := c.Query("service_id")
+	clerkOrgID := auth.OrganizationID(c)
 
-	if incidentID == "" || clerkOrgID == "" || serviceID == "" {
+	if incidentID == "" || clerkOrgID == "" {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Incident ID, Organization ID, and Service ID are required",
+			"error": "Incident ID and Organization ID are required",
 		})
 	}
 
@@ -368,15 +558,17 @@ func DeleteIncident(c *fiber.Ctx) error {
 		})
 	}
 
-	// Then verify the incident belongs to both the organization and service
+	// Then verify the incident belongs to the organization, with the affected
+	// services the deletion is announced for
 	var incident models.Incident
-	if err := db.Where("id = ? AND organization_id = ? AND service_id = ?",
-		incidentID, org.ID, serviceID).First(&incident).Error; err != nil {
+	if err := db.Preload("Services").Where("id = ? AND organization_id = ?", incidentID, org.ID).First(&incident).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
-			"error": "Incident not found or does not belong to the specified organization and service",
+			"error": "Incident not found or does not belong to the organization",
 		})
 	}
 
+	affected := incidentServiceIDs(db, incident.ID)
+
 	// Start a transaction to ensure all deletions are atomic
 	tx := db.Begin()
 
@@ -388,6 +580,14 @@ func DeleteIncident(c *fiber.Ctx) error {
 		})
 	}
 
+	// Unlink the affected services
+	if err := tx.Where("incident_id = ?", incident.ID).Delete(&models.IncidentService{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete incident services",
+		})
+	}
+
 	// Delete the incident
 	if err := tx.Delete(&incident).Error; err != nil {
 		tx.Rollback()
@@ -403,6 +603,13 @@ func DeleteIncident(c *fiber.Ctx) error {
 		})
 	}
 
+	refreshServiceStatuses(db, affected, StatusCause{
+		Source:      models.StatusSourceIncident,
+		ActorUserID: RequestActor(c),
+		Reason:      fmt.Sprintf("Incident %d deleted: %s", incident.ID, incident.Title),
+	})
+	events.PublishIncident(org.Slug, "deleted", incident)
+
 	return c.Status(200).JSON(fiber.Map{
 		"message": "Incident and all related data deleted successfully",
 	})
@@ -411,12 +618,11 @@ func DeleteIncident(c *fiber.Ctx) error {
 // UpdateIncident updates an incident's information
 func UpdateIncident(c *fiber.Ctx) error {
 	incidentID := c.Params("id")
-	clerkOrgID := c.Query("organization_id")
-	serviceID := c.Query("service_id")
+	clerkOrgID := auth.OrganizationID(c)
 
-	if incidentID == "" || clerkOrgID == "" || serviceID == "" {
+	if incidentID == "" || clerkOrgID == "" {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Incident ID, Organization ID, and Service ID are required",
+			"error": "Incident ID and Organization ID are required",
 		})
 	}
 
@@ -446,12 +652,33 @@ func UpdateIncident(c *fiber.Ctx) error {
 		})
 	}
 
-	// Then verify the incident belongs to both the organization and service
+	// Then verify the incident belongs to the organization
 	var incident models.Incident
-	if err := db.Where("id = ? AND organization_id = ? AND service_id = ?",
-		incidentID, org.ID, serviceID).First(&incident).Error; err != nil {
+	if err := db.Where("id = ? AND organization_id = ?", incidentID, org.ID).First(&incident).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
-			"error": "Incident not found or does not belong to the specified organization and service",
+			"error": "Incident not found or does not belong to the organization",
+		})
+	}
+
+	var links []models.IncidentService
+	if len(req.Services) > 0 {
+		var err error
+		if links, err = incidentServices(db, org, req.Services); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// Save the updated incident and its affected services together
+	tx := db.Begin()
+
+	// Read the incident again under a row lock, so that a concurrent update
+	// cannot move it through the lifecycle meanwhile
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&incident, incident.ID).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update incident",
 		})
 	}
 
@@ -462,16 +689,63 @@ func UpdateIncident(c *fiber.Ctx) error {
 	if req.Description != "" {
 		incident.Description = req.Description
 	}
-	if req.Status != "" {
-		incident.Status = req.Status
+
+	// Status changes go through the incident lifecycle
+	changeStatus := req.Status != "" && req.Status != incident.Status
+	if changeStatus && !CanTransition(incident.Status, req.Status) {
+		tx.Rollback()
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Cannot move incident from " + incident.Status + " to " + req.Status,
+		})
 	}
 
-	// Save the updated incident
-	if err := db.Save(&incident).Error; err != nil {
+	// Services that stop being affected need their status refreshed as well
+	affectedBefore := incidentServiceIDs(tx, incident.ID)
+
+	if err := tx.Omit("Services", "Updates").Save(&incident).Error; err != nil {
+		tx.Rollback()
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update incident",
 		})
 	}
 
+	if links != nil {
+		if err := replaceIncidentServices(tx, incident.ID, links); err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update affected services",
+			})
+		}
+	}
+
+	if changeStatus {
+		if _, err := TransitionIncident(tx, &incident, req.Status, "", time.Now()); err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update incident status",
+			})
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	refreshServiceStatuses(db, append(affectedBefore, incidentServiceIDs(db, incident.ID)...), StatusCause{
+		Source:      models.StatusSourceIncident,
+		ActorUserID: RequestActor(c),
+		Reason:      fmt.Sprintf("Incident %d updated: %s", incident.ID, incident.Title),
+	})
+	publishIncident(db, org.Slug, "updated", incident.ID)
+
+	incident, err := loadIncident(db, incident.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch incident",
+		})
+	}
+
 	return c.Status(200).JSON(incident)
 }
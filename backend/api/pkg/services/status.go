@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// statusSeverity ranks service statuses from best to worst. Maintenance shows
+// over an operational service but not over an actual outage.
+var statusSeverity = map[string]int{
+	models.ServiceStatusOperational:      0,
+	models.ServiceStatusUnderMaintenance: 1,
+	models.ServiceStatusDegraded:         2,
+	models.ServiceStatusPartialOutage:    3,
+	models.ServiceStatusMajorOutage:      4,
+}
+
+// WorstStatus returns the most severe of the given service statuses
+func WorstStatus(statuses ...string) string {
+	worst := models.ServiceStatusOperational
+	for _, status := range statuses {
+		if statusSeverity[status] > statusSeverity[worst] {
+			worst = status
+		}
+	}
+	return worst
+}
+
+// StatusCause describes who or what changed a service status, for the audit trail
+type StatusCause struct {
+	Source      string // One of the models.StatusSource constants
+	ActorUserID string // Clerk user ID, empty for automatic changes
+	Reason      string
+}
+
+// SetServiceStatus saves a new status for the service and notifies real-time
+// subscribers. It does nothing when the status is unchanged.
+func SetServiceStatus(database *gorm.DB, service *models.Service, status string, cause StatusCause) error {
+	if service.Status == status {
+		return nil
+	}
+
+	var org models.Organization
+	if err := database.Where("id = ?", service.OrganizationID).First(&org).Error; err != nil {
+		return err
+	}
+
+	previousStatus := service.Status
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(service).Update("status", status).Error; err != nil {
+			return err
+		}
+		service.Status = status
+
+		return recordStatusChange(tx, *service, previousStatus, cause)
+	})
+	if err != nil {
+		service.Status = previousStatus
+		return err
+	}
+
+	events.PublishStatusChange(org.Slug, *service)
+
+	return nil
+}
+
+// recordStatusChange stores the service's transition from oldStatus to its
+// current status, which the uptime history and the audit trail are built from
+func recordStatusChange(tx *gorm.DB, service models.Service, oldStatus string, cause StatusCause) error {
+	if service.Status == oldStatus {
+		return nil
+	}
+
+	change := models.ServiceStatusChange{
+		ServiceID:      fmt.Sprint(service.ID),
+		ServiceName:    service.Name,
+		OrganizationID: service.OrganizationID,
+		OldStatus:      oldStatus,
+		NewStatus:      service.Status,
+		ChangedAt:      time.Now(),
+		Source:         cause.Source,
+		ActorUserID:    cause.ActorUserID,
+		Reason:         cause.Reason,
+	}
+
+	return tx.Create(&change).Error
+}
+
+// RequestActor returns the Clerk user ID of the user making the request
+func RequestActor(c *fiber.Ctx) string {
+	return auth.UserID(c)
+}
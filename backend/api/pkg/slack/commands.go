@@ -0,0 +1,478 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/subscribers"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// responseTimeout bounds a post to the response_url of an interaction
+const responseTimeout = 5 * time.Second
+
+// usage is shown for /status help and unknown commands
+const usage = "Usage:\n" +
+	"`/status incident create <title> | <service>, <service>` opens an incident\n" +
+	"`/status update <id> <message>` posts an update to an incident\n" +
+	"`/status resolve <id> [message]` resolves an incident\n" +
+	"`/status service <name> <status>` sets the status of a service, e.g. `degraded`\n" +
+	"`/status link <code>` links this workspace with the code an admin created"
+
+// serviceStatuses are the statuses /status service accepts
+var serviceStatuses = []string{
+	models.ServiceStatusOperational,
+	models.ServiceStatusDegraded,
+	models.ServiceStatusPartialOutage,
+	models.ServiceStatusMajorOutage,
+	models.ServiceStatusUnderMaintenance,
+}
+
+// Response is a message sent back to Slack
+type Response struct {
+	ResponseType    string        `json:"response_type,omitempty"` // in_channel or ephemeral
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	Text            string        `json:"text"`
+	Blocks          []interface{} `json:"blocks,omitempty"`
+}
+
+// caller is the organization member a Slack user acts as
+type caller struct {
+	org    models.Organization
+	member models.OrganizationMember
+}
+
+// HandleCommand runs a /status slash command. Slack shows the response to the
+// channel, or only to the caller for help and errors.
+func HandleCommand(c *fiber.Ctx) error {
+	secret := os.Getenv("SLACK_SIGNING_SECRET")
+	if secret == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Slack is not configured",
+		})
+	}
+
+	if err := verifyRequest(c, secret); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	// Linking happens before the workspace is known to any organization
+	if args, rest := splitArgs(c.FormValue("text"), 1); len(args) > 0 && args[0] == "link" {
+		return c.JSON(linkWorkspace(database, c.FormValue("team_id"), rest, time.Now()))
+	}
+
+	who, err := findCaller(database, c.FormValue("team_id"), c.FormValue("user_id"))
+	if err != nil {
+		return c.JSON(ephemeral(err.Error()))
+	}
+
+	return c.JSON(runCommand(database, who, c.FormValue("text")))
+}
+
+// linkWorkspace links the workspace a /status link command was signed for
+// to the organization that created the code
+func linkWorkspace(database *gorm.DB, teamID string, code string, now time.Time) Response {
+	if teamID == "" || code == "" {
+		return ephemeral("Usage: `/status link <code>`, with the code an admin created in PopenStatus")
+	}
+
+	var org models.Organization
+	if err := database.Where("slack_link_code = ? AND slack_link_code_expires_at > ?", code, now).First(&org).Error; err != nil {
+		return ephemeral("This link code is invalid or has expired, ask an admin for a new one")
+	}
+
+	var taken int64
+	if err := database.Model(&models.Organization{}).
+		Where("slack_team_id = ? AND id <> ?", teamID, org.ID).Count(&taken).Error; err != nil {
+		log.Printf("slack: failed to link workspace %s: %v", teamID, err)
+		return ephemeral("Failed to link this workspace")
+	}
+	if taken > 0 {
+		return ephemeral("This Slack workspace is already linked to another organization")
+	}
+
+	if err := database.Model(&org).Updates(map[string]interface{}{
+		"slack_team_id":              teamID,
+		"slack_link_code":            "",
+		"slack_link_code_expires_at": nil,
+	}).Error; err != nil {
+		log.Printf("slack: failed to link workspace %s: %v", teamID, err)
+		return ephemeral("Failed to link this workspace")
+	}
+
+	return ephemeral("This workspace is now linked to " + org.Name + ". Ask an admin to link your Slack account to run commands.")
+}
+
+// interactionPayload is the part of a Slack block_actions payload the
+// incident buttons need
+type interactionPayload struct {
+	Type string `json:"type"`
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// HandleInteraction handles the buttons of incident messages, which move the
+// incident to the status of the button and replace the message
+func HandleInteraction(c *fiber.Ctx) error {
+	secret := os.Getenv("SLACK_SIGNING_SECRET")
+	if secret == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Slack is not configured",
+		})
+	}
+
+	if err := verifyRequest(c, secret); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var payload interactionPayload
+	if err := json.Unmarshal([]byte(c.FormValue("payload")), &payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid interaction payload",
+		})
+	}
+
+	// Slack expects an empty acknowledgement, the outcome goes to the response URL
+	if payload.Type != "block_actions" || len(payload.Actions) == 0 || payload.Actions[0].ActionID != "incident_status" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	database := db.GetDB()
+
+	response := ephemeral("")
+	who, err := findCaller(database, payload.Team.ID, payload.User.ID)
+	if err == nil {
+		response = setIncidentStatus(database, who, payload.Actions[0].Value)
+	} else {
+		response.Text = err.Error()
+	}
+
+	if err := respond(c.Context(), payload.ResponseURL, response); err != nil {
+		log.Printf("slack: failed to respond to interaction: %v", err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// findCaller maps a Slack user of a workspace to the member of the
+// organization linked to the workspace
+func findCaller(database *gorm.DB, teamID string, userID string) (caller, error) {
+	var who caller
+
+	if teamID == "" || database.Where("slack_team_id = ?", teamID).First(&who.org).Error != nil {
+		return who, errors.New("This Slack workspace is not linked to a PopenStatus organization")
+	}
+
+	if userID == "" || database.Where("organization_id = ? AND slack_user_id = ?", who.org.ID, userID).First(&who.member).Error != nil {
+		return who, errors.New("Your Slack account is not linked to a member of " + who.org.Name + ", ask an admin to link it")
+	}
+
+	return who, nil
+}
+
+// runCommand carries out the text of a /status command
+func runCommand(database *gorm.DB, who caller, text string) Response {
+	args, rest := splitArgs(text, 1)
+	if len(args) == 0 || args[0] == "help" {
+		return ephemeral(usage)
+	}
+
+	// Every command changes the status page, which viewers may not do
+	if !auth.HasRole(who.member.Role, auth.RoleResponder) {
+		return ephemeral("Only responders and admins can change the status page")
+	}
+
+	switch args[0] {
+	case "incident":
+		sub, rest := splitArgs(rest, 1)
+		if len(sub) == 0 || sub[0] != "create" {
+			return ephemeral(usage)
+		}
+		return createIncident(database, who, rest)
+
+	case "update":
+		id, message := splitArgs(rest, 1)
+		if len(id) == 0 || message == "" {
+			return ephemeral("Usage: `/status update <id> <message>`")
+		}
+		return updateIncident(database, who, id[0], "", message)
+
+	case "resolve":
+		id, message := splitArgs(rest, 1)
+		if len(id) == 0 {
+			return ephemeral("Usage: `/status resolve <id> [message]`")
+		}
+		return updateIncident(database, who, id[0], models.IncidentStatusResolved, message)
+
+	case "service":
+		return setServiceStatus(database, who, rest)
+	}
+
+	return ephemeral("Unknown command `" + args[0] + "`\n" + usage)
+}
+
+// createIncident opens an incident from "<title> | <service>, <service>"
+func createIncident(database *gorm.DB, who caller, text string) Response {
+	title, serviceList, _ := strings.Cut(text, "|")
+	title = strings.TrimSpace(title)
+	if title == "" || strings.TrimSpace(serviceList) == "" {
+		return ephemeral("Usage: `/status incident create <title> | <service>, <service>`")
+	}
+
+	var affected []services.AffectedService
+	for _, name := range strings.Split(serviceList, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		service, err := findService(database, who.org, name)
+		if err != nil {
+			return ephemeral(err.Error())
+		}
+		affected = append(affected, services.AffectedService{ServiceID: fmt.Sprint(service.ID)})
+	}
+
+	incident := models.Incident{
+		Title:  title,
+		Status: models.IncidentStatusInvestigating,
+	}
+	if err := services.OpenIncident(database, who.org, &incident, affected, who.member.ClerkUserID); err != nil {
+		var inputErr *services.InputError
+		if errors.As(err, &inputErr) {
+			return ephemeral(err.Error())
+		}
+		return ephemeral("Failed to create incident")
+	}
+
+	return incidentMessage(database, who, incident.ID, "Opened")
+}
+
+// updateIncident posts an update to an incident, moving it to the given
+// status unless it is empty
+func updateIncident(database *gorm.DB, who caller, incidentID string, status string, message string) Response {
+	incidentID = strings.TrimPrefix(incidentID, "#")
+
+	var incident models.Incident
+	if err := database.Where("id = ? AND organization_id = ?", incidentID, who.org.ID).First(&incident).Error; err != nil {
+		return ephemeral("Incident " + incidentID + " not found")
+	}
+
+	if status != "" && status == incident.Status {
+		return ephemeral("Incident " + incidentID + " is already " + status)
+	}
+
+	previousStatus := incident.Status
+	_, err := services.AddIncidentUpdate(database, who.org, &incident, status, message, who.member.ClerkUserID)
+	if errors.Is(err, services.ErrIllegalTransition) {
+		return ephemeral("Cannot move incident " + incidentID + " from " + previousStatus + " to " + status)
+	}
+	if err != nil {
+		return ephemeral("Failed to update incident")
+	}
+
+	action := "Updated"
+	switch status {
+	case "":
+	case models.IncidentStatusResolved:
+		action = "Resolved"
+	default:
+		action = "Moved to " + status
+	}
+	return incidentMessage(database, who, incident.ID, action)
+}
+
+// setIncidentStatus handles an incident button, whose value is "<id>:<status>"
+func setIncidentStatus(database *gorm.DB, who caller, value string) Response {
+	if !auth.HasRole(who.member.Role, auth.RoleResponder) {
+		return ephemeral("Only responders and admins can change the status page")
+	}
+
+	incidentID, status, ok := strings.Cut(value, ":")
+	if !ok {
+		return ephemeral("Unknown incident action")
+	}
+
+	response := updateIncident(database, who, incidentID, status, "")
+	if response.ResponseType == "in_channel" {
+		response.ReplaceOriginal = true
+	}
+	return response
+}
+
+// setServiceStatus sets a service's status from "<name> <status>"
+func setServiceStatus(database *gorm.DB, who caller, text string) Response {
+	text = strings.TrimSpace(text)
+	cut := strings.LastIndexFunc(text, func(r rune) bool { return r == ' ' || r == '\t' })
+	if cut < 0 {
+		return ephemeral("Usage: `/status service <name> <status>`")
+	}
+
+	name := strings.TrimSpace(text[:cut])
+	status := strings.ToLower(strings.ReplaceAll(text[cut+1:], "-", "_"))
+	if !contains(serviceStatuses, status) {
+		return ephemeral("Unknown status `" + status + "`, use one of " + strings.Join(serviceStatuses, ", "))
+	}
+
+	service, err := findService(database, who.org, name)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+
+	err = services.ChangeServiceStatus(database, who.org, &service, status, nil, services.StatusCause{
+		Source:      models.StatusSourceManual,
+		ActorUserID: who.member.ClerkUserID,
+		Reason:      "Set from Slack",
+	})
+	if err != nil {
+		var inputErr *services.InputError
+		if errors.As(err, &inputErr) {
+			return ephemeral(err.Error())
+		}
+		return ephemeral("Failed to update service")
+	}
+
+	return inChannel(fmt.Sprintf("<@%s> set *%s* to *%s*\n<%s|View status page>",
+		who.member.SlackUserID, service.Name, utils.Humanize(status), subscribers.StatusPageURL(who.org)))
+}
+
+// incidentMessage describes an incident, with buttons moving it forward
+func incidentMessage(database *gorm.DB, who caller, incidentID uint, action string) Response {
+	var incident models.Incident
+	if err := database.Preload("Services.Service").First(&incident, incidentID).Error; err != nil {
+		return ephemeral("Failed to fetch incident")
+	}
+
+	names := make([]string, 0, len(incident.Services))
+	for _, link := range incident.Services {
+		names = append(names, link.Service.Name)
+	}
+
+	text := fmt.Sprintf("*Incident %d: %s*\nStatus: %s\nAffected services: %s\n_%s by <@%s>_",
+		incident.ID, incident.Title, utils.Humanize(incident.Status), strings.Join(names, ", "), action, who.member.SlackUserID)
+
+	buttons := []interface{}{}
+	for _, status := range []string{models.IncidentStatusIdentified, models.IncidentStatusMonitoring, models.IncidentStatusResolved} {
+		if !services.CanTransition(incident.Status, status) {
+			continue
+		}
+		button := map[string]interface{}{
+			"type":      "button",
+			"action_id": "incident_status",
+			"text":      map[string]interface{}{"type": "plain_text", "text": utils.Humanize(status)},
+			"value":     fmt.Sprintf("%d:%s", incident.ID, status),
+		}
+		if status == models.IncidentStatusResolved {
+			button["style"] = "primary"
+		}
+		buttons = append(buttons, button)
+	}
+	buttons = append(buttons, map[string]interface{}{
+		"type": "button",
+		"text": map[string]interface{}{"type": "plain_text", "text": "View status page"},
+		"url":  subscribers.StatusPageURL(who.org),
+	})
+
+	return Response{
+		ResponseType: "in_channel",
+		Text:         text,
+		Blocks: []interface{}{
+			map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": text}},
+			map[string]interface{}{"type": "actions", "elements": buttons},
+		},
+	}
+}
+
+// findService looks a service of the organization up by name, ignoring case
+func findService(database *gorm.DB, org models.Organization, name string) (models.Service, error) {
+	var service models.Service
+	if err := database.Where("organization_id = ? AND LOWER(name) = LOWER(?)", org.ID, name).First(&service).Error; err != nil {
+		return service, errors.New("Service " + name + " not found")
+	}
+	return service, nil
+}
+
+// respond posts a response to the response URL of an interaction
+func respond(ctx context.Context, responseURL string, response Response) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, responseTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("unexpected status code " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+func ephemeral(text string) Response {
+	return Response{ResponseType: "ephemeral", Text: text}
+}
+
+func inChannel(text string) Response {
+	return Response{ResponseType: "in_channel", Text: text}
+}
+
+// splitArgs returns the first n words of a command and the text after them
+func splitArgs(text string, n int) ([]string, string) {
+	var args []string
+	rest := strings.TrimSpace(text)
+	for len(args) < n && rest != "" {
+		word, after, _ := strings.Cut(rest, " ")
+		args = append(args, word)
+		rest = strings.TrimSpace(after)
+	}
+	return args, rest
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
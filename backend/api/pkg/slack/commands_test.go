@@ -0,0 +1,165 @@
+package slack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB returns an in-memory database with an organization linked to a
+// workspace and two of its services
+func openTestDB(t *testing.T) (*gorm.DB, models.Organization) {
+	t.Helper()
+
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := database.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.AutoMigrate(
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.Service{},
+		&models.Incident{},
+		&models.IncidentService{},
+		&models.IncidentUpdate{},
+		&models.Maintenance{},
+		&models.Monitor{},
+		&models.ServiceStatusChange{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	org := models.Organization{ID: "org", ClerkOrgID: "org_1", Name: "Acme", Slug: "acme", SlackTeamID: "T1"}
+	if err := database.Create(&org).Error; err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	for _, name := range []string{"Public API", "Web App"} {
+		service := models.Service{Name: name, Status: models.ServiceStatusOperational, UserID: "user_1", OrganizationID: org.ID}
+		if err := database.Create(&service).Error; err != nil {
+			t.Fatalf("create service: %v", err)
+		}
+	}
+	return database, org
+}
+
+func memberWithRole(org models.Organization, role string) caller {
+	return caller{org: org, member: models.OrganizationMember{ClerkUserID: "user_1", OrganizationID: org.ID, Role: role, SlackUserID: "U1"}}
+}
+
+func TestRunCommand(t *testing.T) {
+	database, org := openTestDB(t)
+	responder := memberWithRole(org, auth.RoleResponder)
+
+	tests := []struct {
+		text     string
+		wantType string
+		wantText string
+	}{
+		{"", "ephemeral", "Usage:"},
+		{"help", "ephemeral", "Usage:"},
+		{"frobnicate", "ephemeral", "Unknown command `frobnicate`"},
+		{"incident", "ephemeral", "Usage:"},
+		{"incident create Database outage", "ephemeral", "Usage: `/status incident create"},
+		{"incident create | Public API", "ephemeral", "Usage: `/status incident create"},
+		{"incident create Database outage | Billing", "ephemeral", "Service Billing not found"},
+		{"incident create Database outage | public api,  Web App ", "in_channel", "*Incident 1: Database outage*"},
+		{"update #1 Restarting the primary", "in_channel", "Updated by <@U1>"},
+		{"update 1", "ephemeral", "Usage: `/status update"},
+		{"update #42 Still down", "ephemeral", "Incident 42 not found"},
+		{"resolve", "ephemeral", "Usage: `/status resolve"},
+		{"resolve #1", "in_channel", "Resolved by <@U1>"},
+		{"resolve 1", "ephemeral", "Incident 1 is already resolved"},
+		{"service Web App major-outage", "in_channel", "set *Web App* to *Major outage*"},
+		{"service Web App sideways", "ephemeral", "Unknown status `sideways`"},
+		{"service Degraded", "ephemeral", "Usage: `/status service"},
+		{"service Billing degraded", "ephemeral", "Service Billing not found"},
+	}
+
+	for _, tt := range tests {
+		response := runCommand(database, responder, tt.text)
+		if response.ResponseType != tt.wantType || !strings.Contains(response.Text, tt.wantText) {
+			t.Errorf("%q: got %s %q, want %s containing %q", tt.text, response.ResponseType, response.Text, tt.wantType, tt.wantText)
+		}
+	}
+
+	var incident models.Incident
+	if err := database.Preload("Services").Preload("Updates").First(&incident).Error; err != nil {
+		t.Fatalf("load incident: %v", err)
+	}
+	if incident.Status != models.IncidentStatusResolved || len(incident.Services) != 2 || len(incident.Updates) != 3 {
+		t.Errorf("incident is %s with %d services and %d updates", incident.Status, len(incident.Services), len(incident.Updates))
+	}
+
+	var service models.Service
+	database.Where("name = ?", "Web App").First(&service)
+	if service.Status != models.ServiceStatusMajorOutage {
+		t.Errorf("Web App is %s, want major_outage", service.Status)
+	}
+}
+
+func TestRunCommandRequiresAResponder(t *testing.T) {
+	database, org := openTestDB(t)
+
+	tests := []struct {
+		role    string
+		text    string
+		allowed bool
+	}{
+		{auth.RoleViewer, "help", true},
+		{auth.RoleViewer, "service Public API degraded", false},
+		{auth.RoleViewer, "incident create Outage | Public API", false},
+		{auth.RoleViewer, "resolve #1", false},
+		{"", "service Public API degraded", false},
+		{auth.RoleResponder, "service Public API degraded", true},
+		{auth.RoleAdmin, "service Public API partial_outage", true},
+		{auth.RoleOwner, "service Public API operational", true},
+	}
+
+	for _, tt := range tests {
+		response := runCommand(database, memberWithRole(org, tt.role), tt.text)
+		denied := response.Text == "Only responders and admins can change the status page"
+		if denied == tt.allowed {
+			t.Errorf("%s running %q: got %q", tt.role, tt.text, response.Text)
+		}
+	}
+
+	var incidents int64
+	database.Model(&models.Incident{}).Count(&incidents)
+	if incidents != 0 {
+		t.Errorf("a viewer opened %d incidents", incidents)
+	}
+
+	// Incident buttons are gated the same way
+	if response := setIncidentStatus(database, memberWithRole(org, auth.RoleViewer), "1:resolved"); !strings.Contains(response.Text, "Only responders") {
+		t.Errorf("a viewer pressed an incident button: %q", response.Text)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		text     string
+		n        int
+		wantArgs string
+		wantRest string
+	}{
+		{"  update   #12  Restarting  now ", 2, "update,#12", "Restarting  now"},
+		{"help", 1, "help", ""},
+		{"", 1, "", ""},
+	}
+
+	for _, tt := range tests {
+		args, rest := splitArgs(tt.text, tt.n)
+		if strings.Join(args, ",") != tt.wantArgs || rest != tt.wantRest {
+			t.Errorf("splitArgs(%q, %d) = %q, %q", tt.text, tt.n, args, rest)
+		}
+	}
+}
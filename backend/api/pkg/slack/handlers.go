@@ -0,0 +1,108 @@
+package slack
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+type LinkMemberRequest struct {
+	SlackUserID string `json:"slack_user_id"` // e.g., "U0123ABCD", empty to unlink
+}
+
+// linkCodeTTL is how long a workspace link code can be used
+const linkCodeTTL = 15 * time.Minute
+
+// CreateLinkCode returns a one-time code that links the Slack workspace it
+// is run from with /status link. Only a request signed by Slack tells which
+// workspace that is, so nobody can claim a workspace they are not in.
+func CreateLinkCode(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate link code",
+		})
+	}
+	code := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(linkCodeTTL)
+
+	if err := database.Model(&org).Updates(map[string]interface{}{
+		"slack_link_code":            code,
+		"slack_link_code_expires_at": expiresAt,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate link code",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code":       code,
+		"command":    "/status link " + code,
+		"expires_at": expiresAt,
+	})
+}
+
+// LinkMember sets the Slack user a member of the organization acts as
+// through the /status command
+func LinkMember(c *fiber.Ctx) error {
+	var req LinkMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var member models.OrganizationMember
+	if err := database.Where("id = ? AND organization_id = ?", c.Params("id"), org.ID).First(&member).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Member not found or does not belong to the organization",
+		})
+	}
+
+	if req.SlackUserID != "" {
+		var taken int64
+		if err := database.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND slack_user_id = ? AND id <> ?", org.ID, req.SlackUserID, member.ID).
+			Count(&taken).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to link Slack user",
+			})
+		}
+		if taken > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Slack user is already linked to another member",
+			})
+		}
+	}
+
+	if err := database.Model(&member).Update("slack_user_id", req.SlackUserID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to link Slack user",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(member)
+}
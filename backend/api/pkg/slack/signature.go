@@ -0,0 +1,56 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxRequestAge is how old a signed request may be before it is taken for a replay
+const maxRequestAge = 5 * time.Minute
+
+var (
+	// ErrInvalidSignature is returned for requests not signed with the app's signing secret
+	ErrInvalidSignature = errors.New("invalid Slack signature")
+	// ErrStaleRequest is returned for signed requests older than maxRequestAge
+	ErrStaleRequest = errors.New("Slack request is too old")
+)
+
+// Sign returns the X-Slack-Signature of a request body sent at the given
+// Unix timestamp
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks that a request comes from Slack, following
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifySignature(secret string, timestamp string, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > maxRequestAge || age < -maxRequestAge {
+		return ErrStaleRequest
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyRequest checks the signature of a request made to the Slack endpoints
+func verifyRequest(c *fiber.Ctx, secret string) error {
+	return VerifySignature(secret,
+		c.Get("X-Slack-Request-Timestamp"), c.Get("X-Slack-Signature"), c.Body(), time.Now())
+}
@@ -0,0 +1,42 @@
+package slack
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&command=%2Fstatus&text=help")
+	now := time.Unix(1531420618, 0)
+
+	at := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid", at(0), Sign(secret, at(0), body), body, nil},
+		{"valid within the allowed age", at(-4 * time.Minute), Sign(secret, at(-4*time.Minute), body), body, nil},
+		{"tampered body", at(0), Sign(secret, at(0), body), []byte(string(body) + "!"), ErrInvalidSignature},
+		{"other secret", at(0), Sign("another secret", at(0), body), body, ErrInvalidSignature},
+		{"signature of another timestamp", at(0), Sign(secret, at(-time.Second), body), body, ErrInvalidSignature},
+		{"missing signature", at(0), "", body, ErrInvalidSignature},
+		{"stale", at(-6 * time.Minute), Sign(secret, at(-6*time.Minute), body), body, ErrStaleRequest},
+		{"future", at(6 * time.Minute), Sign(secret, at(6*time.Minute), body), body, ErrStaleRequest},
+		{"invalid timestamp", "yesterday", Sign(secret, "yesterday", body), body, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		if err := VerifySignature(secret, tt.timestamp, tt.signature, tt.body, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package slo
+
+import (
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/uptime"
+	"gorm.io/gorm"
+)
+
+// Budget is the state of an SLO's error budget over its rolling window.
+// Amounts are in seconds for status based SLOs and in checks for check based ones.
+type Budget struct {
+	Unit                string    `json:"unit"`
+	Availability        *float64  `json:"availability"` // Nil without data in the window
+	TargetPercentage    float64   `json:"target_percentage"`
+	ErrorBudget         float64   `json:"error_budget"` // Bad amount allowed in the window
+	Consumed            float64   `json:"consumed"`
+	Remaining           float64   `json:"remaining"`
+	RemainingPercentage float64   `json:"remaining_percentage"`
+	BurnRate            float64   `json:"burn_rate"` // Over the burn rate window, 1 spends the budget exactly at the end of the window
+	BurnRateThreshold   float64   `json:"burn_rate_threshold"`
+	WindowStart         time.Time `json:"window_start"`
+	WindowEnd           time.Time `json:"window_end"`
+}
+
+// Compute returns the SLO's error budget as of now
+func Compute(database *gorm.DB, slo models.SLO, now time.Time) (Budget, error) {
+	var service models.Service
+	if err := database.Where("id = ?", slo.ServiceID).First(&service).Error; err != nil {
+		return Budget{}, err
+	}
+
+	budget := Budget{
+		Unit:              unit(slo),
+		TargetPercentage:  slo.TargetPercentage,
+		BurnRateThreshold: slo.BurnRateThreshold,
+		WindowStart:       now.AddDate(0, 0, -slo.WindowDays),
+		WindowEnd:         now,
+	}
+
+	good, total, err := measure(database, slo, service, budget.WindowStart, now)
+	if err != nil {
+		return budget, err
+	}
+
+	allowedBadRatio := 1 - slo.TargetPercentage/100
+
+	if total > 0 {
+		availability := good / total * 100
+		budget.Availability = &availability
+	}
+	budget.ErrorBudget = allowedBadRatio * total
+	budget.Consumed = total - good
+	budget.Remaining = budget.ErrorBudget - budget.Consumed
+	if budget.ErrorBudget > 0 {
+		budget.RemainingPercentage = budget.Remaining / budget.ErrorBudget * 100
+	}
+
+	burnStart := now.Add(-time.Duration(slo.BurnRateWindowHours) * time.Hour)
+	good, total, err = measure(database, slo, service, burnStart, now)
+	if err != nil {
+		return budget, err
+	}
+	if total > 0 && allowedBadRatio > 0 {
+		budget.BurnRate = (total - good) / total / allowedBadRatio
+	}
+
+	return budget, nil
+}
+
+// measure returns the good and total amount between from and to
+func measure(database *gorm.DB, slo models.SLO, service models.Service, from time.Time, to time.Time) (good float64, total float64, err error) {
+	if slo.Source == "checks" {
+		return measureChecks(database, slo.ServiceID, from, to)
+	}
+
+	durations, err := uptime.Durations(database, service, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for status, duration := range durations {
+		// Planned maintenance does not spend the error budget
+		if status == models.ServiceStatusUnderMaintenance {
+			continue
+		}
+		total += duration.Seconds()
+		// Same rule as the uptime history: degraded performance still counts as up
+		if status == models.ServiceStatusOperational || status == models.ServiceStatusDegraded {
+			good += duration.Seconds()
+		}
+	}
+
+	return good, total, nil
+}
+
+// measureChecks counts the successful and all check results of the service's monitors
+func measureChecks(database *gorm.DB, serviceID string, from time.Time, to time.Time) (float64, float64, error) {
+	var counts struct {
+		Good  int64
+		Total int64
+	}
+
+	err := database.Model(&models.MonitorCheckResult{}).
+		Select("COUNT(*) FILTER (WHERE success) AS good, COUNT(*) AS total").
+		Where("monitor_id IN (SELECT id FROM monitors WHERE service_id = ?)", serviceID).
+		Where("checked_at >= ? AND checked_at < ?", from, to).
+		Scan(&counts).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return float64(counts.Good), float64(counts.Total), nil
+}
+
+func unit(slo models.SLO) string {
+	if slo.Source == "checks" {
+		return "checks"
+	}
+	return "seconds"
+}
@@ -0,0 +1,114 @@
+package slo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// evaluationInterval is how often burn rates are checked against their thresholds
+const evaluationInterval = 5 * time.Minute
+
+// Evaluator periodically computes every SLO's burn rate and opens or resolves
+// its alert
+type Evaluator struct {
+	db *gorm.DB
+}
+
+func NewEvaluator(database *gorm.DB) *Evaluator {
+	return &Evaluator{db: database}
+}
+
+// Start runs the evaluator in the background until the context is cancelled
+func (e *Evaluator) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(evaluationInterval)
+		defer ticker.Stop()
+
+		for {
+			e.EvaluateAll(time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// EvaluateAll evaluates every SLO, logging the ones that fail
+func (e *Evaluator) EvaluateAll(now time.Time) {
+	var slos []models.SLO
+	if err := e.db.Find(&slos).Error; err != nil {
+		log.Printf("slo: failed to load SLOs: %v", err)
+		return
+	}
+
+	for _, slo := range slos {
+		if err := e.Evaluate(slo, now); err != nil {
+			log.Printf("slo: failed to evaluate SLO %d: %v", slo.ID, err)
+		}
+	}
+}
+
+// Evaluate opens an alert when the SLO's burn rate exceeds its threshold and
+// resolves the open alert once it no longer does. Both are published as
+// events, so webhooks, chat channels and subscribers hear about them.
+func (e *Evaluator) Evaluate(slo models.SLO, now time.Time) error {
+	budget, err := Compute(e.db, slo, now)
+	if err != nil {
+		return err
+	}
+
+	var alert models.SLOAlert
+	err = e.db.Where("slo_id = ? AND resolved_at IS NULL", slo.ID).First(&alert).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	open := err == nil
+	burning := budget.BurnRate > slo.BurnRateThreshold
+
+	var action string
+	switch {
+	case burning && !open:
+		alert = models.SLOAlert{
+			SLOID:          slo.ID,
+			ServiceID:      slo.ServiceID,
+			OrganizationID: slo.OrganizationID,
+			BurnRate:       budget.BurnRate,
+			Threshold:      slo.BurnRateThreshold,
+			TriggeredAt:    now,
+		}
+		if err := e.db.Create(&alert).Error; err != nil {
+			return err
+		}
+		log.Printf("slo: %q burn rate %.2f exceeds %.2f", slo.Name, budget.BurnRate, slo.BurnRateThreshold)
+		action = models.SLOAlertTriggered
+
+	case !burning && open:
+		if err := e.db.Model(&alert).Update("resolved_at", now).Error; err != nil {
+			return err
+		}
+		log.Printf("slo: %q burn rate back to %.2f", slo.Name, budget.BurnRate)
+		alert.ResolvedAt = &now
+		action = models.SLOAlertResolved
+
+	default:
+		return nil
+	}
+
+	var org models.Organization
+	if err := e.db.Where("id = ?", slo.OrganizationID).First(&org).Error; err != nil {
+		return err
+	}
+	alert.SLO = slo
+	events.PublishSLOAlert(org.Slug, action, alert)
+
+	return nil
+}
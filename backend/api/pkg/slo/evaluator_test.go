@@ -0,0 +1,91 @@
+package slo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestEvaluatePublishesAlerts(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := database.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := database.AutoMigrate(&models.Organization{}, &models.Service{}, &models.Monitor{},
+		&models.MonitorCheckResult{}, &models.SLO{}, &models.SLOAlert{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	org := models.Organization{ID: "org", ClerkOrgID: "org_1", Name: "Acme", Slug: "slo-alerts"}
+	service := models.Service{Name: "API", Status: models.ServiceStatusOperational, UserID: "user_1", OrganizationID: org.ID}
+	database.Create(&org)
+	database.Create(&service)
+	monitor := models.Monitor{Name: "API health", Type: "http", ServiceID: fmt.Sprint(service.ID), OrganizationID: org.ID}
+	database.Create(&monitor)
+
+	// Half of the checks of the last hour failed
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		database.Create(&models.MonitorCheckResult{MonitorID: monitor.ID, Success: i%2 == 0, CheckedAt: now.Add(-time.Duration(i+1) * time.Minute)})
+	}
+
+	slo := models.SLO{
+		Name: "API availability", ServiceID: fmt.Sprint(service.ID), OrganizationID: org.ID,
+		TargetPercentage: 99, WindowDays: 30, Source: "checks",
+		BurnRateThreshold: 2, BurnRateWindowHours: 1,
+	}
+	database.Create(&slo)
+
+	published := make(chan events.Event, 4)
+	events.AddListener(func(slug string, event events.Event) {
+		if slug == org.Slug {
+			published <- event
+		}
+	})
+
+	// next returns the next event published for the organization
+	next := func() events.Event {
+		select {
+		case event := <-published:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no event published")
+		}
+		return events.Event{}
+	}
+
+	evaluator := NewEvaluator(database)
+	if err := evaluator.Evaluate(slo, now); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	event := next()
+	if event.Type != events.TypeSLOAlert || event.Action != models.SLOAlertTriggered || event.SLOAlert.SLO.Name != slo.Name {
+		t.Errorf("burning SLO published %+v", event)
+	}
+	if event.Kind() != events.KindSLOAlerts || len(event.ServiceIDs()) != 1 || event.ServiceIDs()[0] != slo.ServiceID {
+		t.Errorf("SLO alert is of kind %q about services %v", event.Kind(), event.ServiceIDs())
+	}
+
+	// An alert that stays open is not published again
+	if err := evaluator.Evaluate(slo, now); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+
+	slo.BurnRateThreshold = 100
+	if err := evaluator.Evaluate(slo, now); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	event = next()
+	if event.Action != models.SLOAlertResolved || event.SLOAlert.ResolvedAt == nil {
+		t.Errorf("recovered SLO published %+v", event)
+	}
+}
@@ -0,0 +1,338 @@
+package slo
+
+import (
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateSLORequest struct {
+	Name                string  `json:"name" validate:"required"`
+	ServiceID           string  `json:"service_id" validate:"required"`
+	OrganizationID      string  `json:"organization_id" validate:"required"`
+	TargetPercentage    float64 `json:"target_percentage" validate:"required,gt=0,lt=100"`
+	WindowDays          int     `json:"window_days" validate:"omitempty,min=1,max=365"`
+	Source              string  `json:"source" validate:"omitempty,oneof=status checks"`
+	BurnRateThreshold   float64 `json:"burn_rate_threshold" validate:"omitempty,gt=0"`
+	BurnRateWindowHours int     `json:"burn_rate_window_hours" validate:"omitempty,min=1,max=168"`
+}
+
+type UpdateSLORequest struct {
+	Name                string  `json:"name"`
+	TargetPercentage    float64 `json:"target_percentage" validate:"omitempty,gt=0,lt=100"`
+	WindowDays          int     `json:"window_days" validate:"omitempty,min=1,max=365"`
+	Source              string  `json:"source" validate:"omitempty,oneof=status checks"`
+	BurnRateThreshold   float64 `json:"burn_rate_threshold" validate:"omitempty,gt=0"`
+	BurnRateWindowHours int     `json:"burn_rate_window_hours" validate:"omitempty,min=1,max=168"`
+}
+
+// Defaults follow the usual fast-burn alert: a 14.4x burn over one hour
+// spends 2% of a 30 day budget
+const (
+	defaultWindowDays          = 30
+	defaultBurnRateThreshold   = 14.4
+	defaultBurnRateWindowHours = 1
+)
+
+// CreateSLO creates an SLO for a service of the organization
+func CreateSLO(c *fiber.Ctx) error {
+	var req CreateSLORequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// The organization always comes from the session
+	req.OrganizationID = auth.OrganizationID(c)
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", req.OrganizationID).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var service models.Service
+	if err := database.Where("id = ? AND organization_id = ?", req.ServiceID, org.ID).First(&service).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Service not found or does not belong to the organization",
+		})
+	}
+
+	slo := models.SLO{
+		Name:                req.Name,
+		ServiceID:           req.ServiceID,
+		OrganizationID:      org.ID,
+		TargetPercentage:    req.TargetPercentage,
+		WindowDays:          req.WindowDays,
+		Source:              req.Source,
+		BurnRateThreshold:   req.BurnRateThreshold,
+		BurnRateWindowHours: req.BurnRateWindowHours,
+	}
+	if slo.WindowDays == 0 {
+		slo.WindowDays = defaultWindowDays
+	}
+	if slo.Source == "" {
+		slo.Source = "status"
+	}
+	if slo.BurnRateThreshold == 0 {
+		slo.BurnRateThreshold = defaultBurnRateThreshold
+	}
+	if slo.BurnRateWindowHours == 0 {
+		slo.BurnRateWindowHours = defaultBurnRateWindowHours
+	}
+
+	if err := database.Create(&slo).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create SLO",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(slo)
+}
+
+// ListSLOs lists the SLOs of an organization, optionally filtered by service
+func ListSLOs(c *fiber.Ctx) error {
+	clerkOrgID := auth.OrganizationID(c)
+	serviceID := c.Query("service_id")
+
+	if clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Organization ID is required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	query := database.Where("organization_id = ?", org.ID)
+	if serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+
+	var slos []models.SLO
+	if err := query.Find(&slos).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch SLOs",
+		})
+	}
+
+	return c.Status(200).JSON(slos)
+}
+
+// UpdateSLO updates an SLO's target and alerting settings
+func UpdateSLO(c *fiber.Ctx) error {
+	sloID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if sloID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "SLO ID and Organization ID are required",
+		})
+	}
+
+	var req UpdateSLORequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var slo models.SLO
+	if err := database.Where("id = ? AND organization_id = ?", sloID, org.ID).First(&slo).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "SLO not found or does not belong to the organization",
+		})
+	}
+
+	// Update the SLO fields if they are provided
+	if req.Name != "" {
+		slo.Name = req.Name
+	}
+	if req.TargetPercentage != 0 {
+		slo.TargetPercentage = req.TargetPercentage
+	}
+	if req.WindowDays != 0 {
+		slo.WindowDays = req.WindowDays
+	}
+	if req.Source != "" {
+		slo.Source = req.Source
+	}
+	if req.BurnRateThreshold != 0 {
+		slo.BurnRateThreshold = req.BurnRateThreshold
+	}
+	if req.BurnRateWindowHours != 0 {
+		slo.BurnRateWindowHours = req.BurnRateWindowHours
+	}
+
+	if err := database.Save(&slo).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update SLO",
+		})
+	}
+
+	return c.Status(200).JSON(slo)
+}
+
+// DeleteSLO deletes an SLO and its alerts
+func DeleteSLO(c *fiber.Ctx) error {
+	sloID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if sloID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "SLO ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var slo models.SLO
+	if err := database.Where("id = ? AND organization_id = ?", sloID, org.ID).First(&slo).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "SLO not found or does not belong to the organization",
+		})
+	}
+
+	// Start a transaction to ensure all deletions are atomic
+	tx := database.Begin()
+
+	if err := tx.Where("slo_id = ?", slo.ID).Delete(&models.SLOAlert{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete SLO alerts",
+		})
+	}
+
+	if err := tx.Delete(&slo).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete SLO",
+		})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"message": "SLO deleted successfully",
+	})
+}
+
+// GetSLOBudget returns the SLO's current error budget and burn rate
+func GetSLOBudget(c *fiber.Ctx) error {
+	sloID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if sloID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "SLO ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var slo models.SLO
+	if err := database.Where("id = ? AND organization_id = ?", sloID, org.ID).First(&slo).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "SLO not found or does not belong to the organization",
+		})
+	}
+
+	budget, err := Compute(database, slo, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to compute error budget",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"slo":    slo,
+		"budget": budget,
+	})
+}
+
+// ListSLOAlerts returns the burn rate alerts of an SLO, most recent first
+func ListSLOAlerts(c *fiber.Ctx) error {
+	sloID := c.Params("id")
+	clerkOrgID := auth.OrganizationID(c)
+
+	if sloID == "" || clerkOrgID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "SLO ID and Organization ID are required",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var alerts []models.SLOAlert
+	if err := database.Where("slo_id = ? AND organization_id = ?", sloID, org.ID).
+		Order("triggered_at DESC").
+		Find(&alerts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch SLO alerts",
+		})
+	}
+
+	return c.Status(200).JSON(alerts)
+}
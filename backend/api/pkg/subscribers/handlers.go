@@ -0,0 +1,361 @@
+package subscribers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SubscribeRequest struct {
+	Email      string   `json:"email" validate:"required,email"`
+	ServiceIDs []string `json:"service_ids"` // Empty for every service
+	EventKinds []string `json:"event_kinds"` // Defaults to incidents and maintenance
+}
+
+type PreferencesRequest struct {
+	ServiceIDs []string `json:"service_ids"`
+	EventKinds []string `json:"event_kinds" validate:"required,min=1"`
+}
+
+// PreferencesResponse describes what a subscriber is notified about
+type PreferencesResponse struct {
+	Email      string   `json:"email"`
+	ServiceIDs []string `json:"service_ids"`
+	EventKinds []string `json:"event_kinds"`
+}
+
+// confirmCooldown is how long a pending subscriber waits before signing up
+// again sends another confirmation email
+const confirmCooldown = 15 * time.Minute
+
+// defaultEventKinds are the kinds a subscriber who picks none hears about
+var defaultEventKinds = []string{events.KindIncidents, events.KindMaintenance}
+
+func newPreferencesResponse(subscriber models.Subscriber) PreferencesResponse {
+	preferences := events.ParsePreferences(subscriber.ServiceIDs, subscriber.EventKinds)
+	response := PreferencesResponse{
+		Email:      subscriber.Email,
+		ServiceIDs: preferences.ServiceIDs,
+		EventKinds: preferences.Kinds,
+	}
+	if response.ServiceIDs == nil {
+		response.ServiceIDs = []string{}
+	}
+	return response
+}
+
+// Subscribe signs a visitor of the organization's status page up for email
+// notifications. The subscription only starts once the emailed link is
+// followed; the response is the same whether or not the address was known.
+func Subscribe(c *fiber.Ctx) error {
+	var req SubscribeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("slug = ?", c.Params("slug")).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	if len(req.EventKinds) == 0 {
+		req.EventKinds = defaultEventKinds
+	}
+	if err := checkPreferences(database, org.ID, req.ServiceIDs, req.EventKinds); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var subscriber models.Subscriber
+	err := database.Where("organization_id = ? AND email = ?", org.ID, req.Email).First(&subscriber).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch subscriber",
+		})
+	}
+
+	// Confirmed subscribers change their preferences through the link in
+	// their emails, so that nobody else can change them. Pending ones are
+	// only emailed again after a cooldown, so that nobody can flood an inbox.
+	recentlySent := subscriber.ConfirmSentAt != nil && time.Since(*subscriber.ConfirmSentAt) < confirmCooldown
+	if subscriber.ConfirmedAt == nil && !recentlySent {
+		subscriber.ServiceIDs = strings.Join(req.ServiceIDs, ",")
+		subscriber.EventKinds = strings.Join(req.EventKinds, ",")
+		if err := startConfirmation(database, org, &subscriber, req.Email); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to subscribe",
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Check your inbox to confirm the subscription",
+	})
+}
+
+// startConfirmation gives a new or still unconfirmed subscriber a fresh
+// confirmation token and emails it
+func startConfirmation(database *gorm.DB, org models.Organization, subscriber *models.Subscriber, email string) error {
+	confirmToken, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	subscriber.Email = email
+	subscriber.OrganizationID = org.ID
+	subscriber.ConfirmToken = confirmToken
+	subscriber.ConfirmSentAt = &now
+	if subscriber.UnsubscribeToken == "" {
+		if subscriber.UnsubscribeToken, err = newToken(); err != nil {
+			return err
+		}
+	}
+
+	if err := database.Save(subscriber).Error; err != nil {
+		return err
+	}
+
+	return QueueConfirmation(database, org, *subscriber)
+}
+
+// ConfirmSubscription starts the subscription whose confirmation link was followed
+func ConfirmSubscription(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	database := db.GetDB()
+
+	var subscriber models.Subscriber
+	if token == "" || database.Where("confirm_token = ?", token).First(&subscriber).Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Confirmation link is invalid or was already used",
+		})
+	}
+
+	if err := database.Model(&subscriber).Updates(map[string]interface{}{
+		"confirm_token": "",
+		"confirmed_at":  time.Now(),
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to confirm subscription",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Subscription confirmed",
+	})
+}
+
+// unsubscribePage asks to confirm an unsubscribe link with a POST, which
+// link prefetchers and mail scanners do not send
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe from {{.Organization}}</title></head>
+<body>
+<p>Stop emailing status updates from {{.Organization}} to {{.Email}}?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+</body>
+</html>
+`))
+
+// ConfirmUnsubscribe shows the page of an unsubscribe link. Following the
+// link does not unsubscribe anybody, the page's button does.
+func ConfirmUnsubscribe(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	database := db.GetDB()
+
+	var subscriber models.Subscriber
+	if token == "" || database.Preload("Organization").Where("unsubscribe_token = ?", token).First(&subscriber).Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unsubscribe link is invalid or was already used",
+		})
+	}
+
+	var page bytes.Buffer
+	if err := unsubscribePage.Execute(&page, map[string]string{
+		"Organization": subscriber.Organization.Name,
+		"Email":        subscriber.Email,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to render page",
+		})
+	}
+
+	c.Type("html")
+	return c.Status(fiber.StatusOK).Send(page.Bytes())
+}
+
+// Unsubscribe removes the subscriber of the unsubscribe link along with its
+// queued emails. It answers the page's button and one-click POST requests
+// from mail clients.
+func Unsubscribe(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	database := db.GetDB()
+
+	var subscriber models.Subscriber
+	if token == "" || database.Where("unsubscribe_token = ?", token).First(&subscriber).Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unsubscribe link is invalid or was already used",
+		})
+	}
+
+	// Delete for good so the address can subscribe again later
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("subscriber_id = ?", subscriber.ID).Delete(&models.SubscriberNotification{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&subscriber).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unsubscribe",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "You have been unsubscribed",
+	})
+}
+
+// GetPreferences returns what the subscriber of a preferences link is notified about
+func GetPreferences(c *fiber.Ctx) error {
+	subscriber, err := findByUnsubscribeToken(db.GetDB(), c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newPreferencesResponse(subscriber))
+}
+
+// UpdatePreferences replaces the services and event kinds the subscriber of a
+// preferences link is notified about
+func UpdatePreferences(c *fiber.Ctx) error {
+	var req PreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	database := db.GetDB()
+
+	subscriber, err := findByUnsubscribeToken(database, c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := checkPreferences(database, subscriber.OrganizationID, req.ServiceIDs, req.EventKinds); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	subscriber.ServiceIDs = strings.Join(req.ServiceIDs, ",")
+	subscriber.EventKinds = strings.Join(req.EventKinds, ",")
+	if err := database.Model(&subscriber).Select("service_ids", "event_kinds").Updates(&subscriber).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update preferences",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newPreferencesResponse(subscriber))
+}
+
+// findByUnsubscribeToken loads the subscriber of an unsubscribe or preferences link
+func findByUnsubscribeToken(database *gorm.DB, token string) (models.Subscriber, error) {
+	var subscriber models.Subscriber
+	if token == "" || database.Where("unsubscribe_token = ?", token).First(&subscriber).Error != nil {
+		return subscriber, errors.New("Preferences link is invalid")
+	}
+	return subscriber, nil
+}
+
+// checkPreferences checks that the event kinds are known and the services
+// belong to the organization
+func checkPreferences(database *gorm.DB, orgID string, serviceIDs []string, eventKinds []string) error {
+	if err := events.ValidateKinds(eventKinds); err != nil {
+		return err
+	}
+	return services.CheckServiceIDs(database, orgID, serviceIDs)
+}
+
+// ListSubscribers lists the organization's subscribers, optionally only the
+// confirmed or pending ones
+func ListSubscribers(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	query := database.Where("organization_id = ?", org.ID)
+	switch c.Query("status") {
+	case "confirmed":
+		query = query.Where("confirmed_at IS NOT NULL")
+	case "pending":
+		query = query.Where("confirmed_at IS NULL")
+	}
+
+	var subscribers []models.Subscriber
+	if err := query.Order("created_at DESC").Find(&subscribers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch subscribers",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(subscribers)
+}
+
+// newToken returns a secret for confirmation and unsubscribe links
+func newToken() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
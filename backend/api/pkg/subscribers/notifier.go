@@ -0,0 +1,346 @@
+package subscribers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// tickInterval is how often the notifier looks for emails that are due
+	tickInterval = 10 * time.Second
+	// maxConcurrentSends bounds how many emails are sent at the same time
+	maxConcurrentSends = 5
+	// maxAttempts is how many times an email is tried before it fails for good
+	maxAttempts = 5
+	// initialBackoff is the delay before the first retry, doubled for every further one
+	initialBackoff = time.Minute
+	// batchSize is how many due emails a single run sends
+	batchSize = 200
+	// defaultPublicURL is where the API is reached when PUBLIC_API_URL is not set
+	defaultPublicURL = "http://localhost:8000"
+)
+
+// Notifier queues emails for the confirmed subscribers of an organization
+// when its incidents, maintenances and service statuses change, and sends
+// them with retries
+type Notifier struct {
+	db     *gorm.DB
+	sender Sender
+	wake   chan struct{}
+}
+
+func NewNotifier(database *gorm.DB, sender Sender) *Notifier {
+	return &Notifier{
+		db:     database,
+		sender: sender,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// defaultNotifier is the started notifier, woken up when emails are queued
+// outside of it
+var defaultNotifier *Notifier
+
+// Start queues emails for every published event and sends them in the
+// background until the context is cancelled
+func (n *Notifier) Start(ctx context.Context) {
+	defaultNotifier = n
+
+	events.AddListener(func(slug string, event events.Event) {
+		if err := n.Enqueue(slug, event); err != nil {
+			log.Printf("subscribers: failed to queue %s event: %v", event.Type, err)
+		}
+	})
+
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := n.Run(ctx, time.Now()); err != nil {
+				log.Printf("subscribers: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-n.wake:
+			}
+		}
+	}()
+}
+
+// Enqueue queues an email about the event for every confirmed subscriber of
+// the organization who chose its kind and one of its services. Incidents are
+// announced through their latest update, so an incident event without a new
+// update sends nothing.
+func (n *Notifier) Enqueue(slug string, event events.Event) error {
+	var org models.Organization
+	if err := n.db.Where("slug = ?", slug).First(&org).Error; err != nil {
+		return err
+	}
+
+	key, subject, body, err := n.compose(org, event)
+	if err != nil || key == "" {
+		return err
+	}
+
+	var subscribers []models.Subscriber
+	if err := n.db.Where("organization_id = ? AND confirmed_at IS NOT NULL", org.ID).Find(&subscribers).Error; err != nil {
+		return err
+	}
+
+	for _, subscriber := range subscribers {
+		if !events.ParsePreferences(subscriber.ServiceIDs, subscriber.EventKinds).Matches(event) {
+			continue
+		}
+		if err := queueNotification(n.db, subscriber, key, subject, body); err != nil {
+			return err
+		}
+	}
+
+	n.notify()
+	return nil
+}
+
+// compose returns the deduplication key, subject and body of the email about
+// an event, with an empty key for events subscribers are not told about
+func (n *Notifier) compose(org models.Organization, event events.Event) (key string, subject string, body string, err error) {
+	switch {
+	case event.Incident != nil && event.Action != "deleted":
+		var update models.IncidentUpdate
+		err := n.db.Where("incident_id = ?", fmt.Sprint(event.Incident.ID)).
+			Order("timestamp DESC, id DESC").First(&update).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", "", "", nil
+		}
+		if err != nil {
+			return "", "", "", err
+		}
+
+		incident := event.Incident
+		switch {
+		case event.Action == "created":
+			subject = fmt.Sprintf("[%s] New incident: %s", org.Name, incident.Title)
+		case update.Status == models.IncidentStatusResolved:
+			subject = fmt.Sprintf("[%s] Resolved: %s", org.Name, incident.Title)
+		default:
+			subject = fmt.Sprintf("[%s] Update on %s", org.Name, incident.Title)
+		}
+
+		body = fmt.Sprintf("%s\n\nStatus: %s\n%s\n\n%s",
+			incident.Title, utils.Humanize(update.Status), update.Message, StatusPageURL(org))
+		return fmt.Sprintf("incident_update:%d", update.ID), subject, body, nil
+
+	case event.Maintenance != nil:
+		maintenance := event.Maintenance
+		switch event.Action {
+		case "created":
+			subject = fmt.Sprintf("[%s] Scheduled maintenance: %s", org.Name, maintenance.Title)
+		case "started":
+			subject = fmt.Sprintf("[%s] Maintenance in progress: %s", org.Name, maintenance.Title)
+		case "completed":
+			subject = fmt.Sprintf("[%s] Maintenance completed: %s", org.Name, maintenance.Title)
+		case "cancelled":
+			subject = fmt.Sprintf("[%s] Maintenance cancelled: %s", org.Name, maintenance.Title)
+		default:
+			return "", "", "", nil
+		}
+
+		body = fmt.Sprintf("%s\n\n%s\n\nScheduled from %s to %s\n\n%s",
+			maintenance.Title, maintenance.Description,
+			maintenance.ScheduledStart.UTC().Format(time.RFC1123), maintenance.ScheduledEnd.UTC().Format(time.RFC1123),
+			StatusPageURL(org))
+		return fmt.Sprintf("maintenance:%d:%s", maintenance.ID, event.Action), subject, body, nil
+
+	case event.SLOAlert != nil:
+		alert := event.SLOAlert
+		if event.Action == models.SLOAlertResolved {
+			subject = fmt.Sprintf("[%s] SLO burn rate back to normal: %s", org.Name, alert.SLO.Name)
+			body = fmt.Sprintf("%s\n\nThe error budget no longer burns faster than %.1f times the sustainable rate.\n\n%s",
+				alert.SLO.Name, alert.Threshold, StatusPageURL(org))
+		} else {
+			subject = fmt.Sprintf("[%s] SLO burning too fast: %s", org.Name, alert.SLO.Name)
+			body = fmt.Sprintf("%s\n\nThe error budget burns %.1f times faster than sustainable, the threshold is %.1f.\n\n%s",
+				alert.SLO.Name, alert.BurnRate, alert.Threshold, StatusPageURL(org))
+		}
+		return fmt.Sprintf("slo_alert:%d:%s", alert.ID, event.Action), subject, body, nil
+
+	case event.Type == events.TypeStatusChange:
+		// Status changes are announced through the recorded transition, so
+		// saving a service without changing its status sends nothing
+		var change models.ServiceStatusChange
+		err := n.db.Where("service_id = ?", event.Service).
+			Order("changed_at DESC, id DESC").First(&change).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", "", "", nil
+		}
+		if err != nil {
+			return "", "", "", err
+		}
+
+		var service models.Service
+		if err := n.db.Where("id = ?", event.Service).First(&service).Error; err != nil {
+			return "", "", "", err
+		}
+
+		subject = fmt.Sprintf("[%s] %s: %s", org.Name, service.Name, utils.Humanize(change.NewStatus))
+		body = fmt.Sprintf("%s\n\nStatus: %s\n%s\n\n%s",
+			service.Name, utils.Humanize(change.NewStatus), change.Reason, StatusPageURL(org))
+		return fmt.Sprintf("status_change:%d", change.ID), subject, body, nil
+	}
+
+	return "", "", "", nil
+}
+
+// queueNotification adds an email for the subscriber unless one with the
+// same key was already queued
+func queueNotification(database *gorm.DB, subscriber models.Subscriber, key string, subject string, body string) error {
+	now := time.Now()
+	notification := models.SubscriberNotification{
+		SubscriberID:  subscriber.ID,
+		DedupKey:      key,
+		Subject:       subject,
+		Body:          body,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: &now,
+	}
+
+	return database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subscriber_id"}, {Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(&notification).Error
+}
+
+// QueueConfirmation queues the email asking a new subscriber to confirm
+func QueueConfirmation(database *gorm.DB, org models.Organization, subscriber models.Subscriber) error {
+	subject := fmt.Sprintf("[%s] Confirm your subscription", org.Name)
+	body := fmt.Sprintf("Please confirm that you want to receive status updates from %s:\n\n%s\n\n"+
+		"If you did not ask for this, you can ignore this email.",
+		org.Name, publicURL()+"/api/subscribers/confirm/"+subscriber.ConfirmToken)
+
+	if err := queueNotification(database, subscriber, "confirm:"+subscriber.ConfirmToken, subject, body); err != nil {
+		return err
+	}
+
+	if defaultNotifier != nil {
+		defaultNotifier.notify()
+	}
+	return nil
+}
+
+// notify sends queued emails right away instead of on the next tick
+func (n *Notifier) notify() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends every pending email that is due by now and waits for them
+func (n *Notifier) Run(ctx context.Context, now time.Time) error {
+	var notifications []models.SubscriberNotification
+	if err := n.db.Preload("Subscriber").
+		Where("status = ? AND next_attempt_at <= ?", models.NotificationStatusPending, now).
+		Order("next_attempt_at ASC").Limit(batchSize).
+		Find(&notifications).Error; err != nil {
+		return fmt.Errorf("failed to load notifications: %w", err)
+	}
+
+	sem := make(chan struct{}, maxConcurrentSends)
+	var wg sync.WaitGroup
+	for i := range notifications {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(notification *models.SubscriberNotification) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := n.Send(ctx, notification, time.Now()); err != nil {
+				log.Printf("subscribers: failed to record notification %d: %v", notification.ID, err)
+			}
+		}(&notifications[i])
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// Send makes one attempt at sending the email and records its outcome,
+// scheduling the next attempt when it failed
+func (n *Notifier) Send(ctx context.Context, notification *models.SubscriberNotification, now time.Time) error {
+	notification.Attempts++
+	notification.Error = ""
+
+	subscriber := notification.Subscriber
+	if subscriber.ID == 0 {
+		// The subscriber unsubscribed after the email was queued
+		notification.Status = models.NotificationStatusFailed
+		notification.NextAttemptAt = nil
+		notification.Error = "Subscriber no longer exists"
+		return n.saveNotification(notification)
+	}
+
+	body := notification.Body
+	unsubscribeURL := publicURL() + "/api/subscribers/unsubscribe/" + subscriber.UnsubscribeToken
+	if !strings.HasPrefix(notification.DedupKey, "confirm:") {
+		body += "\n\n--\nManage preferences: " + publicURL() + "/api/subscribers/preferences/" + subscriber.UnsubscribeToken +
+			"\nUnsubscribe: " + unsubscribeURL
+	}
+
+	err := n.sender.Send(ctx, Message{
+		To:             subscriber.Email,
+		Subject:        notification.Subject,
+		Body:           body,
+		UnsubscribeURL: unsubscribeURL,
+	})
+
+	switch {
+	case err == nil:
+		notification.Status = models.NotificationStatusSent
+		notification.NextAttemptAt = nil
+		notification.SentAt = &now
+	case notification.Attempts >= maxAttempts:
+		notification.Status = models.NotificationStatusFailed
+		notification.NextAttemptAt = nil
+		notification.Error = err.Error()
+	default:
+		next := now.Add(initialBackoff << (notification.Attempts - 1))
+		notification.NextAttemptAt = &next
+		notification.Error = err.Error()
+	}
+
+	return n.saveNotification(notification)
+}
+
+func (n *Notifier) saveNotification(notification *models.SubscriberNotification) error {
+	return n.db.Model(notification).Select(
+		"status", "attempts", "next_attempt_at", "sent_at", "error",
+	).Updates(notification).Error
+}
+
+// publicURL returns the base URL of the API used in email links
+func publicURL() string {
+	if url := os.Getenv("PUBLIC_API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return defaultPublicURL
+}
+
+// StatusPageURL returns the link to the organization's public status
+func StatusPageURL(org models.Organization) string {
+	return publicURL() + "/api/organizations/" + org.Slug + "/status"
+}
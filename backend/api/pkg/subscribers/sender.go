@@ -0,0 +1,149 @@
+package subscribers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds a whole SMTP exchange, so that a stuck server cannot
+// hold up the notifier
+const sendTimeout = 30 * time.Second
+
+// Message is a plain text email
+type Message struct {
+	To             string
+	Subject        string
+	Body           string
+	UnsubscribeURL string // Sent as List-Unsubscribe when set
+}
+
+// Sender delivers emails
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewSenderFromEnv returns an SMTP sender when SMTP_HOST is set, and a sender
+// that only logs the emails otherwise
+func NewSenderFromEnv() Sender {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogSender{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return &SMTPSender{
+		Addr:     net.JoinHostPort(host, port),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+// SMTPSender sends emails through an SMTP server, upgrading to TLS when the
+// server offers it. Without a username it sends unauthenticated, which is
+// what local stand-ins such as MailHog expect.
+type SMTPSender struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message through the SMTP server. It gives up once the
+// context is done or sendTimeout has passed.
+func (s *SMTPSender) Send(ctx context.Context, message Message) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Cancelling the context interrupts the exchange too
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(s.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(s.From, message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// LogSender logs emails instead of sending them, for setups without SMTP
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(ctx context.Context, message Message) error {
+	log.Printf("subscribers: email to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// buildMessage renders the headers and body of an email
+func buildMessage(from string, message Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	if message.UnsubscribeURL != "" {
+		fmt.Fprintf(&buf, "List-Unsubscribe: <%s>\r\n", message.UnsubscribeURL)
+		buf.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,159 @@
+package subscribers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receivedMail is what the SMTP stand-in was asked to deliver
+type receivedMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// startSMTPServer runs a minimal SMTP server that accepts every message and
+// hands it to the returned channel. A stuck server greets nobody.
+func startSMTPServer(t *testing.T, stuck bool) (string, <-chan receivedMail) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan receivedMail, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if stuck {
+				// Hold the connection until the client hangs up
+				go io.Copy(io.Discard, conn)
+				continue
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func serveSMTP(conn net.Conn, received chan<- receivedMail) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 smtp.test ready")
+
+	var mail receivedMail
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 smtp.test")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail.From = strings.Trim(strings.TrimPrefix(command, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.To = append(mail.To, strings.Trim(strings.TrimPrefix(command, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			mail.Data = data.String()
+			received <- mail
+			mail = receivedMail{}
+			reply("250 OK queued")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSenderDelivers(t *testing.T) {
+	addr, received := startSMTPServer(t, false)
+	sender := &SMTPSender{Addr: addr, From: "status@acme.test"}
+
+	err := sender.Send(context.Background(), Message{
+		To:             "bob@example.com",
+		Subject:        "[Acme] API degraded",
+		Body:           "The API is degraded.\nWe are looking into it.",
+		UnsubscribeURL: "https://status.acme.test/api/subscribers/unsubscribe/abc",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var mail receivedMail
+	select {
+	case mail = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message reached the SMTP server")
+	}
+
+	if mail.From != "status@acme.test" {
+		t.Errorf("MAIL FROM = %q", mail.From)
+	}
+	if len(mail.To) != 1 || mail.To[0] != "bob@example.com" {
+		t.Errorf("RCPT TO = %v", mail.To)
+	}
+
+	for _, want := range []string{
+		"From: status@acme.test\r\n",
+		"To: bob@example.com\r\n",
+		"Subject: [Acme] API degraded\r\n",
+		"List-Unsubscribe: <https://status.acme.test/api/subscribers/unsubscribe/abc>\r\n",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n",
+		"\r\n\r\nThe API is degraded.\r\nWe are looking into it.",
+	} {
+		if !strings.Contains(mail.Data, want) {
+			t.Errorf("message is missing %q:\n%s", want, mail.Data)
+		}
+	}
+}
+
+func TestSMTPSenderGivesUpOnStuckServers(t *testing.T) {
+	addr, _ := startSMTPServer(t, true)
+	sender := &SMTPSender{Addr: addr, From: "status@acme.test"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := sender.Send(ctx, Message{To: "bob@example.com", Subject: "Hello", Body: "Hi"})
+	if err == nil {
+		t.Fatal("Send succeeded against a server that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send returned after %s, want it to stop with the context", elapsed)
+	}
+}
@@ -0,0 +1,105 @@
+package uptime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetServiceUptime returns the daily uptime of a service over the last days
+// (90 by default), oldest first. Completed days come from the rollups.
+func GetServiceUptime(c *fiber.Ctx) error {
+	orgSlug := c.Params("slug")
+	serviceID := c.Params("id")
+
+	days := c.QueryInt("days", 90)
+	if days < 1 || days > maxHistoryDays {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "days must be between 1 and 365",
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("slug = ?", orgSlug).First(&org).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var service models.Service
+	if err := database.Where("id = ? AND organization_id = ?", serviceID, org.ID).First(&service).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Service not found or does not belong to the organization",
+		})
+	}
+
+	history, err := History(database, service, days, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch uptime history",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"service": fiber.Map{
+			"id":     service.ID,
+			"name":   service.Name,
+			"status": service.Status,
+		},
+		"days":              days,
+		"uptime_percentage": Percentage(history...),
+		"history":           history,
+	})
+}
+
+// History returns the daily uptime of the service over the last days, oldest
+// first. Only today and yesterday, which the roller may not have stored yet,
+// are computed; any other day without a rollup is reported without data.
+func History(database *gorm.DB, service models.Service, days int, now time.Time) ([]models.ServiceUptimeDaily, error) {
+	today := startOfDay(now)
+	first := today.AddDate(0, 0, -(days - 1))
+	yesterday := today.AddDate(0, 0, -1)
+	serviceID := fmt.Sprint(service.ID)
+
+	var rollups []models.ServiceUptimeDaily
+	if err := database.Where("service_id = ? AND day >= ? AND day < ?", serviceID, first, today).
+		Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]models.ServiceUptimeDaily, len(rollups))
+	for _, rollup := range rollups {
+		stored[rollup.Day.Format(time.DateOnly)] = rollup
+	}
+
+	history := make([]models.ServiceUptimeDaily, 0, days)
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if rollup, ok := stored[day.Format(time.DateOnly)]; ok {
+			history = append(history, rollup)
+			continue
+		}
+
+		if day.Before(yesterday) {
+			history = append(history, models.ServiceUptimeDaily{
+				ServiceID:      serviceID,
+				Day:            day,
+				OrganizationID: service.OrganizationID,
+			})
+			continue
+		}
+
+		rollup, err := ComputeDay(database, service, day, now)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, rollup)
+	}
+
+	return history, nil
+}
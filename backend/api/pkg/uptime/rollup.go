@@ -0,0 +1,225 @@
+package uptime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// rollupInterval is how often completed days are rolled up
+	rollupInterval = time.Hour
+	// maxHistoryDays bounds the rollup backfill and the uptime endpoint
+	maxHistoryDays = 365
+)
+
+// ComputeDay computes how long the service spent in each status on the given
+// UTC day, up to now. Time before the service was created is not counted.
+func ComputeDay(database *gorm.DB, service models.Service, day time.Time, now time.Time) (models.ServiceUptimeDaily, error) {
+	dayStart := startOfDay(day)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	rollup := models.ServiceUptimeDaily{
+		ServiceID:      fmt.Sprint(service.ID),
+		Day:            dayStart,
+		OrganizationID: service.OrganizationID,
+	}
+
+	to := dayEnd
+	if now.Before(to) {
+		to = now
+	}
+
+	durations, err := Durations(database, service, dayStart, to)
+	if err != nil {
+		return rollup, err
+	}
+
+	rollup.OperationalSeconds = int64(durations[models.ServiceStatusOperational].Seconds())
+	rollup.DegradedSeconds = int64(durations[models.ServiceStatusDegraded].Seconds())
+	rollup.PartialOutageSeconds = int64(durations[models.ServiceStatusPartialOutage].Seconds())
+	rollup.MajorOutageSeconds = int64(durations[models.ServiceStatusMajorOutage].Seconds())
+	rollup.MaintenanceSeconds = int64(durations[models.ServiceStatusUnderMaintenance].Seconds())
+	rollup.UptimePercentage = Percentage(rollup)
+
+	return rollup, nil
+}
+
+// Durations returns how long the service spent in each status between from
+// and to. Time before the service was created is not counted.
+func Durations(database *gorm.DB, service models.Service, from time.Time, to time.Time) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+
+	if service.CreatedAt.After(from) {
+		from = service.CreatedAt
+	}
+	if !from.Before(to) {
+		return durations, nil
+	}
+
+	status, err := statusAt(database, service, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []models.ServiceStatusChange
+	if err := database.Where("service_id = ? AND changed_at > ? AND changed_at < ?", fmt.Sprint(service.ID), from, to).
+		Order("changed_at ASC").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	cursor := from
+	for _, change := range changes {
+		durations[status] += change.ChangedAt.Sub(cursor)
+		cursor = change.ChangedAt
+		status = change.NewStatus
+	}
+	durations[status] += to.Sub(cursor)
+
+	return durations, nil
+}
+
+// Percentage returns the share of the tracked time the service was up.
+// Degraded performance counts as up, partial and major outages as down.
+// Time under maintenance is left out of the tracked time.
+func Percentage(rollups ...models.ServiceUptimeDaily) *float64 {
+	var up, total int64
+	for _, r := range rollups {
+		up += r.OperationalSeconds + r.DegradedSeconds
+		total += r.OperationalSeconds + r.DegradedSeconds + r.PartialOutageSeconds + r.MajorOutageSeconds
+	}
+	if total == 0 {
+		return nil
+	}
+
+	percentage := float64(up) / float64(total) * 100
+	return &percentage
+}
+
+// statusAt returns the status the service had at the given time
+func statusAt(database *gorm.DB, service models.Service, at time.Time) (string, error) {
+	serviceID := fmt.Sprint(service.ID)
+
+	var change models.ServiceStatusChange
+	err := database.Where("service_id = ? AND changed_at <= ?", serviceID, at).
+		Order("changed_at DESC").
+		First(&change).Error
+	if err == nil {
+		return change.NewStatus, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	// Before its first recorded change the service had that change's old status
+	err = database.Where("service_id = ? AND changed_at > ?", serviceID, at).
+		Order("changed_at ASC").
+		First(&change).Error
+	if err == nil {
+		if change.OldStatus == "" {
+			return change.NewStatus, nil
+		}
+		return change.OldStatus, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	// No history at all: the service never changed status
+	return service.Status, nil
+}
+
+// Roller periodically stores the daily uptime of every service for the days
+// that are complete
+type Roller struct {
+	db *gorm.DB
+}
+
+func NewRoller(database *gorm.DB) *Roller {
+	return &Roller{db: database}
+}
+
+// Start runs the roller in the background until the context is cancelled
+func (r *Roller) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(rollupInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.RollUp(time.Now()); err != nil {
+				log.Printf("uptime: rollup failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RollUp stores the rollups of every completed day that is not stored yet.
+// A service that fails is logged and picked up again on the next run, the
+// other services are rolled up regardless.
+func (r *Roller) RollUp(now time.Time) error {
+	var services []models.Service
+	if err := r.db.Find(&services).Error; err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		if err := r.rollUpService(service, now); err != nil {
+			log.Printf("uptime: failed to roll up service %d: %v", service.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Roller) rollUpService(service models.Service, now time.Time) error {
+	today := startOfDay(now)
+
+	first := today.AddDate(0, 0, -maxHistoryDays)
+	if created := startOfDay(service.CreatedAt); created.After(first) {
+		first = created
+	}
+
+	var latest models.ServiceUptimeDaily
+	err := r.db.Where("service_id = ?", fmt.Sprint(service.ID)).Order("day DESC").First(&latest).Error
+	if err == nil {
+		if next := startOfDay(latest.Day).AddDate(0, 0, 1); next.After(first) {
+			first = next
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	for day := first; day.Before(today); day = day.AddDate(0, 0, 1) {
+		rollup, err := ComputeDay(r.db, service, day, now)
+		if err != nil {
+			return err
+		}
+
+		if err := r.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "service_id"}, {Name: "day"}},
+			UpdateAll: true,
+		}).Create(&rollup).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
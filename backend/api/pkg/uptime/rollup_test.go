@@ -0,0 +1,101 @@
+package uptime
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := database.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.AutoMigrate(&models.Service{}, &models.ServiceStatusChange{}, &models.ServiceUptimeDaily{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+// createService stores an operational service created the given number of days ago
+func createService(t *testing.T, database *gorm.DB, daysAgo int, now time.Time) models.Service {
+	t.Helper()
+
+	service := models.Service{Name: "API", Status: models.ServiceStatusOperational, UserID: "user_1", OrganizationID: "org"}
+	service.CreatedAt = startOfDay(now).AddDate(0, 0, -daysAgo)
+	if err := database.Create(&service).Error; err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	return service
+}
+
+func TestHistoryComputesOnlyRecentDays(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	service := createService(t, database, 10, now)
+
+	// Three days ago was rolled up at 50%, the days before were not
+	uptime := 50.0
+	database.Create(&models.ServiceUptimeDaily{
+		ServiceID: fmt.Sprint(service.ID), Day: startOfDay(now).AddDate(0, 0, -3), OrganizationID: "org",
+		OperationalSeconds: 43200, MajorOutageSeconds: 43200, UptimePercentage: &uptime,
+	})
+
+	history, err := History(database, service, 5, now)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 5 {
+		t.Fatalf("got %d days, want 5", len(history))
+	}
+
+	want := []*float64{nil, &uptime, nil, ptr(100), ptr(100)}
+	for i, day := range history {
+		if !day.Day.Equal(startOfDay(now).AddDate(0, 0, i-4)) {
+			t.Errorf("day %d is %s", i, day.Day)
+		}
+		if (day.UptimePercentage == nil) != (want[i] == nil) || (want[i] != nil && *day.UptimePercentage != *want[i]) {
+			t.Errorf("%s: uptime %v, want %v", day.Day.Format(time.DateOnly), day.UptimePercentage, want[i])
+		}
+	}
+	// Today is only counted up to now
+	if today := history[4]; today.OperationalSeconds != 12*3600 {
+		t.Errorf("today counts %d operational seconds", today.OperationalSeconds)
+	}
+}
+
+func TestRollUpSkipsFailingServices(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	failing := createService(t, database, 3, now)
+	healthy := createService(t, database, 3, now)
+
+	// A stored rollup that cannot be read back breaks the first service
+	database.Exec("INSERT INTO service_uptime_dailies (service_id, day, organization_id, updated_at) VALUES (?, 'not a day', 'org', ?)",
+		fmt.Sprint(failing.ID), now)
+
+	if err := NewRoller(database).RollUp(now); err != nil {
+		t.Fatalf("RollUp: %v", err)
+	}
+
+	var rolledUp int64
+	database.Model(&models.ServiceUptimeDaily{}).Where("service_id = ?", fmt.Sprint(healthy.ID)).Count(&rolledUp)
+	if rolledUp != 3 {
+		t.Errorf("rolled up %d days of the service after the failing one, want 3", rolledUp)
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
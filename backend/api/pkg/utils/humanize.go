@@ -0,0 +1,12 @@
+package utils
+
+import "strings"
+
+// Humanize turns an enum value such as "partial_outage" into "Partial outage"
+func Humanize(value string) string {
+	value = strings.ReplaceAll(value, "_", " ")
+	if value == "" {
+		return value
+	}
+	return strings.ToUpper(value[:1]) + value[1:]
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrInvalidScheme is returned for webhook URLs that are not http or https
+	ErrInvalidScheme = errors.New("Webhook URL must use http or https")
+	// ErrPrivateAddress is returned for URLs on the API's own network, which
+	// outgoing requests must not reach
+	ErrPrivateAddress = errors.New("Webhook URL must not point to a private, loopback or link-local address")
+)
+
+// CheckURL checks that the API may post to a webhook URL. Host names are only
+// resolved when posting, since they may point elsewhere by then.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidScheme
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// publicIP reports whether the address is outside of the API's own network
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// NewOutboundClient returns a client for URLs that users configure, which
+// refuses to connect to non-public addresses. The address is checked once
+// resolved, right before connecting, so neither DNS records nor redirects can
+// lead a request inside.
+func NewOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect on our behalf, out of reach of the check
+	transport.Proxy = nil
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
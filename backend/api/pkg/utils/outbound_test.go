@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://hooks.example.com/popenstatus", nil},
+		{"http://203.0.113.10:8080/hook", nil},
+		{"ftp://hooks.example.com/", ErrInvalidScheme},
+		{"file:///etc/passwd", ErrInvalidScheme},
+		{"gopher://127.0.0.1:6379/_INFO", ErrInvalidScheme},
+		{"hooks.example.com/no-scheme", ErrInvalidScheme},
+		{"http://127.0.0.1:5432/", ErrPrivateAddress},
+		{"http://localhost.:8000/", nil}, // Names are checked when posting
+		{"http://10.0.0.5/", ErrPrivateAddress},
+		{"http://192.168.1.1/", ErrPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data/", ErrPrivateAddress},
+		{"http://[::1]:8000/", ErrPrivateAddress},
+		{"http://[fd00::1]/", ErrPrivateAddress},
+		{"http://[::ffff:127.0.0.1]/", ErrPrivateAddress},
+		{"http://0.0.0.0:8000/", ErrPrivateAddress},
+	}
+
+	for _, tt := range tests {
+		if err := CheckURL(tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestOutboundClientRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	client := NewOutboundClient(time.Second)
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	// The test server listens on loopback, as would an internal service
+	// reached through a host name that resolves to it
+	for _, url := range []string{server.URL, fmt.Sprintf("http://localhost:%d/", port)} {
+		resp, err := client.Post(url, "application/json", nil)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("POST %s succeeded", url)
+			continue
+		}
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("POST %s: err = %v, want ErrPrivateAddress", url, err)
+		}
+	}
+
+	if reached {
+		t.Error("a request reached the loopback server")
+	}
+}
@@ -0,0 +1,372 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	svix "github.com/svix/svix-webhooks/go"
+	"gorm.io/gorm"
+)
+
+// Event types endpoints can subscribe to
+const (
+	EventServiceStatusChanged = "service.status_changed"
+	EventIncidentCreated      = "incident.created"
+	EventIncidentUpdated      = "incident.updated"
+	EventIncidentReopened     = "incident.reopened"
+	EventIncidentDeleted      = "incident.deleted"
+	EventMaintenanceCreated   = "maintenance.created"
+	EventMaintenanceUpdated   = "maintenance.updated"
+	EventMaintenanceStarted   = "maintenance.started"
+	EventMaintenanceCompleted = "maintenance.completed"
+	EventMaintenanceCancelled = "maintenance.cancelled"
+	EventSLOAlertTriggered    = "slo_alert.triggered"
+	EventSLOAlertResolved     = "slo_alert.resolved"
+
+	// AllEvents subscribes an endpoint to every event type
+	AllEvents = "*"
+)
+
+// EventTypes lists the event types endpoints can subscribe to
+var EventTypes = []string{
+	EventServiceStatusChanged,
+	EventIncidentCreated,
+	EventIncidentUpdated,
+	EventIncidentReopened,
+	EventIncidentDeleted,
+	EventMaintenanceCreated,
+	EventMaintenanceUpdated,
+	EventMaintenanceStarted,
+	EventMaintenanceCompleted,
+	EventMaintenanceCancelled,
+	EventSLOAlertTriggered,
+	EventSLOAlertResolved,
+}
+
+const (
+	// tickInterval is how often the dispatcher looks for deliveries that are due
+	tickInterval = 5 * time.Second
+	// maxConcurrentDeliveries bounds how many deliveries are sent at the same time
+	maxConcurrentDeliveries = 10
+	// deliveryTimeout bounds a single delivery attempt
+	deliveryTimeout = 10 * time.Second
+	// maxAttempts is how many times a delivery is tried before it fails for good
+	maxAttempts = 8
+	// initialBackoff is the delay before the first retry, doubled for every
+	// further one: 30s, 1m, 2m, ... about an hour in total
+	initialBackoff = 30 * time.Second
+	// batchSize is how many due deliveries a single run sends
+	batchSize = 100
+)
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	Timestamp    time.Time   `json:"timestamp"`
+	Organization string      `json:"organization"` // Organization slug
+	Data         interface{} `json:"data"`
+}
+
+// Dispatcher turns published events into deliveries for the subscribed
+// endpoints and sends them, retrying failed ones with exponential backoff
+type Dispatcher struct {
+	db     *gorm.DB
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewDispatcher(database *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		db:     database,
+		client: utils.NewOutboundClient(deliveryTimeout),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start queues deliveries for every published event and sends them in the
+// background until the context is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	events.AddListener(func(slug string, event events.Event) {
+		if err := d.Enqueue(slug, event); err != nil {
+			log.Printf("webhooks: failed to queue %s event: %v", event.Type, err)
+		}
+	})
+
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := d.Run(ctx, time.Now()); err != nil {
+				log.Printf("webhooks: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// Enqueue creates a delivery of the event for every enabled endpoint of the
+// organization subscribed to its type and, when it picked services, to one
+// of the services the event is about
+func (d *Dispatcher) Enqueue(slug string, event events.Event) error {
+	eventType := webhookEventType(event)
+	if eventType == "" {
+		return nil
+	}
+
+	var org models.Organization
+	if err := d.db.Where("slug = ?", slug).First(&org).Error; err != nil {
+		return err
+	}
+
+	var endpoints []models.WebhookEndpoint
+	if err := d.db.Where("organization_id = ? AND enabled = ?", org.ID, true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	var subscribed []models.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if Subscribes(endpoint, eventType) && events.ParsePreferences(endpoint.ServiceIDs, "").Matches(event) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	messageID, err := newMessageID()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:           messageID,
+		Type:         eventType,
+		Timestamp:    event.Timestamp,
+		Organization: slug,
+		Data:         eventData(event),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, endpoint := range subscribed {
+		delivery := models.WebhookDelivery{
+			EndpointID:     endpoint.ID,
+			OrganizationID: org.ID,
+			MessageID:      messageID,
+			EventType:      eventType,
+			Payload:        string(body),
+			Status:         models.DeliveryStatusPending,
+			NextAttemptAt:  &now,
+		}
+		if err := d.db.Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+
+	// Send right away instead of on the next tick
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run sends every pending delivery that is due by now and waits for them
+func (d *Dispatcher) Run(ctx context.Context, now time.Time) error {
+	var deliveries []models.WebhookDelivery
+	if err := d.db.Preload("Endpoint").
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, now).
+		Order("next_attempt_at ASC").Limit(batchSize).
+		Find(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to load deliveries: %w", err)
+	}
+
+	sem := make(chan struct{}, maxConcurrentDeliveries)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := d.Deliver(ctx, delivery, time.Now()); err != nil {
+				log.Printf("webhooks: failed to record delivery %d: %v", delivery.ID, err)
+			}
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// Deliver makes one attempt at sending the delivery and records its outcome,
+// scheduling the next attempt when it failed
+func (d *Dispatcher) Deliver(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) error {
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = 0
+	delivery.Error = ""
+
+	if delivery.Endpoint.ID == 0 || !delivery.Endpoint.Enabled {
+		// The endpoint was deleted or disabled after the event was queued
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.NextAttemptAt = nil
+		delivery.Error = "Endpoint is no longer enabled"
+		return d.saveDelivery(delivery)
+	}
+
+	status, err := d.send(ctx, delivery, now)
+	delivery.ResponseStatus = status
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryStatusSucceeded
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= maxAttempts:
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.NextAttemptAt = nil
+		delivery.Error = err.Error()
+	default:
+		next := now.Add(Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.Error = err.Error()
+	}
+
+	return d.saveDelivery(delivery)
+}
+
+// saveDelivery records the outcome of an attempt without touching the endpoint
+func (d *Dispatcher) saveDelivery(delivery *models.WebhookDelivery) error {
+	return d.db.Model(delivery).Select(
+		"status", "attempts", "next_attempt_at", "last_attempt_at", "response_status", "error",
+	).Updates(delivery).Error
+}
+
+// send posts the payload signed with the endpoint's secret using the Svix
+// headers, so receivers can verify it with any Svix library
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	signer, err := svix.NewWebhook(delivery.Endpoint.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("invalid endpoint secret: %w", err)
+	}
+
+	payload := []byte(delivery.Payload)
+	signature, err := signer.Sign(delivery.MessageID, now, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sign payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PopenStatus-Webhooks")
+	req.Header.Set("svix-id", delivery.MessageID)
+	req.Header.Set("svix-timestamp", fmt.Sprint(now.Unix()))
+	req.Header.Set("svix-signature", signature)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return initialBackoff << (attempts - 1)
+}
+
+// Subscribes reports whether the endpoint receives events of the given type
+func Subscribes(endpoint models.WebhookEndpoint, eventType string) bool {
+	for _, subscribed := range strings.Split(endpoint.EventTypes, ",") {
+		if subscribed == AllEvents || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret returns a signing secret in the whsec_ format Svix libraries expect
+func NewSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func newMessageID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "msg_" + hex.EncodeToString(buf), nil
+}
+
+// webhookEventType maps a hub event to its webhook event type, empty for
+// events that are not sent to webhooks
+func webhookEventType(event events.Event) string {
+	switch event.Type {
+	case events.TypeStatusChange:
+		return EventServiceStatusChanged
+	case events.TypeIncidentUpdate:
+		return "incident." + event.Action
+	case events.TypeMaintenanceUpdate:
+		return "maintenance." + event.Action
+	case events.TypeSLOAlert:
+		return "slo_alert." + event.Action
+	}
+	return ""
+}
+
+// eventData returns the object a hub event is about
+func eventData(event events.Event) interface{} {
+	switch {
+	case event.Incident != nil:
+		return event.Incident
+	case event.Maintenance != nil:
+		return event.Maintenance
+	case event.SLOAlert != nil:
+		return event.SLOAlert
+	default:
+		return map[string]string{
+			"service_id": event.Service,
+			"status":     event.Status,
+		}
+	}
+}
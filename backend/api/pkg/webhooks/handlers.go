@@ -0,0 +1,309 @@
+package webhooks
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsinghdev/PopenStatus/api/pkg/auth"
+	"github.com/apsinghdev/PopenStatus/api/pkg/db"
+	"github.com/apsinghdev/PopenStatus/api/pkg/events"
+	"github.com/apsinghdev/PopenStatus/api/pkg/models"
+	"github.com/apsinghdev/PopenStatus/api/pkg/services"
+	"github.com/apsinghdev/PopenStatus/api/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CreateEndpointRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types" validate:"required,min=1"`
+	ServiceIDs  []string `json:"service_ids"` // Empty for every service
+}
+
+type UpdateEndpointRequest struct {
+	URL         string   `json:"url" validate:"omitempty,url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types"`
+	ServiceIDs  []string `json:"service_ids"` // An empty list selects every service again
+	Enabled     *bool    `json:"enabled"`
+}
+
+// EndpointResponse describes an endpoint without its secret
+type EndpointResponse struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	EventTypes  []string  `json:"event_types"`
+	ServiceIDs  []string  `json:"service_ids"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newEndpointResponse(endpoint models.WebhookEndpoint) EndpointResponse {
+	serviceIDs := events.ParsePreferences(endpoint.ServiceIDs, "").ServiceIDs
+	if serviceIDs == nil {
+		serviceIDs = []string{}
+	}
+
+	return EndpointResponse{
+		ID:          endpoint.ID,
+		URL:         endpoint.URL,
+		Description: endpoint.Description,
+		EventTypes:  strings.Split(endpoint.EventTypes, ","),
+		ServiceIDs:  serviceIDs,
+		Enabled:     endpoint.Enabled,
+		CreatedAt:   endpoint.CreatedAt,
+	}
+}
+
+// CreateEndpoint registers a webhook endpoint for the organization. The
+// signing secret is only returned in this response.
+func CreateEndpoint(c *fiber.Ctx) error {
+	var req CreateEndpointRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	if err := utils.CheckURL(req.URL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if eventType, ok := validEventTypes(req.EventTypes); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unknown event type " + eventType,
+		})
+	}
+
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	if err := services.CheckServiceIDs(database, org.ID, req.ServiceIDs); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate signing secret",
+		})
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:            req.URL,
+		Description:    req.Description,
+		Secret:         secret,
+		EventTypes:     strings.Join(req.EventTypes, ","),
+		ServiceIDs:     strings.Join(req.ServiceIDs, ","),
+		Enabled:        true,
+		OrganizationID: org.ID,
+	}
+
+	if err := database.Create(&endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create webhook endpoint",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"endpoint": newEndpointResponse(endpoint),
+		"secret":   secret,
+	})
+}
+
+// ListEndpoints lists the organization's webhook endpoints
+func ListEndpoints(c *fiber.Ctx) error {
+	database := db.GetDB()
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", auth.OrganizationID(c)).First(&org).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	var endpoints []models.WebhookEndpoint
+	if err := database.Where("organization_id = ?", org.ID).Order("created_at ASC").Find(&endpoints).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch webhook endpoints",
+		})
+	}
+
+	response := make([]EndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, newEndpointResponse(endpoint))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// UpdateEndpoint changes the URL, description, event types, services or
+// enabled state of an endpoint of the organization
+func UpdateEndpoint(c *fiber.Ctx) error {
+	var req UpdateEndpointRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	endpoint, err := findEndpoint(db.GetDB(), auth.OrganizationID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Update the endpoint fields if they are provided
+	if req.URL != "" {
+		if err := utils.CheckURL(req.URL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		endpoint.URL = req.URL
+	}
+	if req.Description != "" {
+		endpoint.Description = req.Description
+	}
+	if len(req.EventTypes) > 0 {
+		if eventType, ok := validEventTypes(req.EventTypes); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unknown event type " + eventType,
+			})
+		}
+		endpoint.EventTypes = strings.Join(req.EventTypes, ",")
+	}
+	if req.ServiceIDs != nil {
+		if err := services.CheckServiceIDs(db.GetDB(), endpoint.OrganizationID, req.ServiceIDs); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		endpoint.ServiceIDs = strings.Join(req.ServiceIDs, ",")
+	}
+	if req.Enabled != nil {
+		endpoint.Enabled = *req.Enabled
+	}
+
+	if err := db.GetDB().Save(&endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update webhook endpoint",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newEndpointResponse(endpoint))
+}
+
+// DeleteEndpoint removes an endpoint of the organization. Its pending
+// deliveries fail on their next attempt.
+func DeleteEndpoint(c *fiber.Ctx) error {
+	endpoint, err := findEndpoint(db.GetDB(), auth.OrganizationID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := db.GetDB().Delete(&endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete webhook endpoint",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Webhook endpoint deleted successfully",
+	})
+}
+
+// ListDeliveries lists the deliveries of an endpoint of the organization,
+// newest first, optionally filtered by status
+func ListDeliveries(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	endpoint, err := findEndpoint(db.GetDB(), auth.OrganizationID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	query := db.GetDB().Where("endpoint_id = ?", endpoint.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch webhook deliveries",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(deliveries)
+}
+
+// findEndpoint loads an endpoint of the organization
+func findEndpoint(database *gorm.DB, clerkOrgID string, endpointID string) (models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+
+	var org models.Organization
+	if err := database.Where("clerk_org_id = ?", clerkOrgID).First(&org).Error; err != nil {
+		return endpoint, errors.New("Organization not found")
+	}
+
+	if err := database.Where("id = ? AND organization_id = ?", endpointID, org.ID).First(&endpoint).Error; err != nil {
+		return endpoint, errors.New("Webhook endpoint not found or does not belong to the organization")
+	}
+
+	return endpoint, nil
+}
+
+// validEventTypes returns the first unknown event type, if any
+func validEventTypes(eventTypes []string) (string, bool) {
+	for _, eventType := range eventTypes {
+		known := eventType == AllEvents
+		for _, candidate := range EventTypes {
+			if eventType == candidate {
+				known = true
+			}
+		}
+		if !known {
+			return eventType, false
+		}
+	}
+	return "", true
+}